@@ -17,6 +17,20 @@ type PathConfig struct {
 	Path         string `yaml:"path"`
 	FetcherName  string `yaml:"fetcherName"`
 	CacheControl string `yaml:"cacheControl"`
+	Background   string `yaml:"background"` // Color transparent images are flattened onto for formats without alpha, default white
+	PresetsOnly  bool   `yaml:"presetsOnly"` // Reject manipulator chains that are not made of presets
+}
+
+// LUTConfig declares a named 3D LUT loaded from a .cube file
+type LUTConfig struct {
+	Name string `yaml:"name"`
+	File string `yaml:"file"`
+}
+
+// PaletteConfig declares a named fixed color palette
+type PaletteConfig struct {
+	Name   string   `yaml:"name"`
+	Colors []string `yaml:"colors"`
 }
 
 // ServerConfig provides server-level configuration options
@@ -27,6 +41,9 @@ type ServerConfig struct {
 	HTTPTimeout        int   `yaml:"httpTimeout"`        // In seconds for HTTP fetcher, default 30
 	MaxImageDimension  int   `yaml:"maxImageDimension"`  // Max image width or height in pixels, default 10000
 	MaxImageSizeBytes  int64 `yaml:"maxImageSizeBytes"`  // Max image file size in bytes, default 50MB
+	MaxCollageSources  int   `yaml:"maxCollageSources"`  // Max number of source images in a collage, default 16
+	UploadEnabled      bool  `yaml:"uploadEnabled"`      // Register the /upload endpoint transforming uploaded images, default false
+	MaxBatchChains     int   `yaml:"maxBatchChains"`     // Max number of chains in a batch request, default 16
 	RateLimit          RateLimitConfig `yaml:"rateLimit"`
 }
 
@@ -44,6 +61,9 @@ type Config struct {
 	Fetchers           []map[string]interface{} `yaml:"fetchers"`
 	Paths              []PathConfig             `yaml:"paths"`
 	Server             ServerConfig             `yaml:"server"`
+	LUTs               []LUTConfig              `yaml:"luts"`
+	Palettes           []PaletteConfig          `yaml:"palettes"`
+	Presets            map[string]string        `yaml:"presets"` // Named manipulator chains used by the preset step
 	FaceAPI            struct {
 		DefaultProvider  string `yaml:"defaultProvider"`
 		MicrosoftFaceAPI struct {
@@ -269,3 +289,19 @@ func (cfg *Config) GetMaxImageSizeBytes() int64 {
 	}
 	return cfg.Server.MaxImageSizeBytes
 }
+
+// GetMaxCollageSources returns the max number of source images in a collage with default fallback
+func (cfg *Config) GetMaxCollageSources() int {
+	if cfg.Server.MaxCollageSources <= 0 {
+		return 16 // Default 16 images
+	}
+	return cfg.Server.MaxCollageSources
+}
+
+// GetMaxBatchChains returns the max number of chains in a batch request with default fallback
+func (cfg *Config) GetMaxBatchChains() int {
+	if cfg.Server.MaxBatchChains <= 0 {
+		return 16 // Default 16 chains
+	}
+	return cfg.Server.MaxBatchChains
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/config"
+	"github.com/erans/thumbla/middleware"
+)
+
+const (
+	// batchFormatJSON returns the results as a JSON list with base64 encoded bodies
+	batchFormatJSON = "json"
+	// batchFormatMultipart returns the results as the parts of a multipart/mixed response
+	batchFormatMultipart = "multipart"
+	// batchFormatZip returns the results as the files of a zip archive
+	batchFormatZip = "zip"
+)
+
+// batchFileExtensions maps output content types to the file extensions used in multipart and zip responses
+var batchFileExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/jpg":  "jpg",
+	"image/png":  "png",
+	"image/webp": "webp",
+	"image/gif":  "gif",
+}
+
+// batchChain is a named manipulators chain of a batch request
+type batchChain struct {
+	Name  string `json:"name"`
+	Chain string `json:"chain"`
+}
+
+// batchRequest renders several chains on a single source image. Format is json, multipart or zip, and is
+// negotiated using the Accept header when missing
+type batchRequest struct {
+	Source pipelineSource `json:"source"`
+	Chains []batchChain   `json:"chains"`
+	Format string         `json:"format"`
+}
+
+// batchResult is the rendered image of a single chain
+type batchResult struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	DPR         string `json:"dpr,omitempty"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Body        []byte `json:"body"`
+}
+
+// fileName returns the name of the result in multipart and zip responses
+func (r *batchResult) fileName() string {
+	return r.Name + "." + batchFileExtensions[r.ContentType]
+}
+
+// isBatchName reports whether name can be used as a chain name, which is also used as a file name
+func isBatchName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return name != "" && len(name) <= 50
+}
+
+// cloneImage copies the image, so every chain of a batch starts from the same untouched source
+func cloneImage(img image.Image) image.Image {
+	clone := image.NewNRGBA(image.Rectangle{Max: img.Bounds().Size()})
+	draw.Draw(clone, clone.Bounds(), img, img.Bounds().Min, draw.Src)
+	return clone
+}
+
+// batchChainError prefixes err with the name of the chain that failed, keeping the status of a requestError
+func batchChainError(name string, err error) error {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return &requestError{reqErr.Status, fmt.Sprintf("chain '%s': %s", name, reqErr.Message)}
+	}
+	return fmt.Errorf("chain '%s': %w", name, err)
+}
+
+// batchFormat returns the requested response format
+func batchFormat(c *fiber.Ctx, format string) (string, error) {
+	switch format {
+	case batchFormatJSON, batchFormatMultipart, batchFormatZip:
+		return format, nil
+	case "":
+		switch c.Accepts(fiber.MIMEApplicationJSON, "multipart/mixed", "application/zip") {
+		case "multipart/mixed":
+			return batchFormatMultipart, nil
+		case "application/zip":
+			return batchFormatZip, nil
+		}
+		return batchFormatJSON, nil
+	}
+
+	return "", fmt.Errorf("unknown format '%s'. Supported formats: json, multipart, zip", format)
+}
+
+// renderBatchChain applies the chain on a copy of the source image and encodes the result
+func renderBatchChain(c *fiber.Ctx, pathConfig *config.PathConfig, name string, actions []*manipulatorAction, src image.Image, contentType string) (*batchResult, error) {
+	// Output settings of the previous chain must not leak into this one
+	resetOutputHeaders(c)
+	defer resetOutputHeaders(c)
+
+	img, err := applyManipulators(c, actions, cloneImage(src))
+	if err != nil {
+		return nil, err
+	}
+
+	// Header values point into buffers that are reused once the headers are reset, so they are copied
+	result := &batchResult{
+		Name:        name,
+		ContentType: strings.Clone(outputContentType(c, contentType)),
+		DPR:         strings.Clone(c.GetRespHeader("Content-DPR")),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+
+	var buf bytes.Buffer
+	if err := encodeImage(c, &buf, result.ContentType, img, pathBackground(c, pathConfig)); err != nil {
+		return nil, err
+	}
+	result.Body = buf.Bytes()
+
+	return result, nil
+}
+
+// writeBatchMultipart writes the results as the parts of a multipart/mixed response
+func writeBatchMultipart(c *fiber.Ctx, results []*batchResult) error {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, result := range results {
+		header := textproto.MIMEHeader{}
+		header.Set(fiber.HeaderContentType, result.ContentType)
+		header.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; name="%s"; filename="%s"`, result.Name, result.fileName()))
+		if result.DPR != "" {
+			header.Set("Content-DPR", result.DPR)
+		}
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(result.Body); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "multipart/mixed; boundary="+writer.Boundary())
+	return c.Send(buf.Bytes())
+}
+
+// writeBatchZip writes the results as the files of a zip archive
+func writeBatchZip(c *fiber.Ctx, results []*batchResult) error {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, result := range results {
+		// Images are already compressed, so they are stored as is
+		file, err := writer.CreateHeader(&zip.FileHeader{Name: result.fileName(), Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(result.Body); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	return c.Send(buf.Bytes())
+}
+
+// HandleBatch fetches and decodes a source image once and renders several named manipulators chains on it,
+// returning all the results in a single response:
+//
+//	{
+//	  "source": {"path": "/i/pics/", "url": "path/to/image.jpg"},
+//	  "chains": [
+//	    {"name": "thumb", "chain": "fit:w=200,h=200/output:f=jpg,q=80"},
+//	    {"name": "large", "chain": "resize:w=1600/output:f=webp"}
+//	  ],
+//	  "format": "zip"
+//	}
+func HandleBatch(c *fiber.Ctx) error {
+	logger := middleware.GetLoggerFromContext(c)
+	logger.Debug().Msg("Handling batch request")
+
+	var req batchRequest
+	decoder := json.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Invalid batch definition: %v", err))
+	}
+
+	format, err := batchFormat(c, req.Format)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	maxChains := config.GetConfig().GetMaxBatchChains()
+	if len(req.Chains) == 0 || len(req.Chains) > maxChains {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("A batch requires between 1 and %d chains", maxChains))
+	}
+
+	pathConfig := findPathConfig(req.Source.Path)
+	if pathConfig == nil {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("No path is configured for source path '%s'", req.Source.Path))
+	}
+
+	if req.Source.URL == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("A source url is required")
+	}
+
+	// Validate all the chains before fetching the source
+	chains := make([][]*manipulatorAction, len(req.Chains))
+	names := map[string]bool{}
+	for i, chain := range req.Chains {
+		if !isBatchName(chain.Name) {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Invalid chain name '%s'. Names are made of letters, digits, '-' and '_'", chain.Name))
+		}
+		if names[chain.Name] {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Duplicate chain name '%s'", chain.Name))
+		}
+		names[chain.Name] = true
+
+		if chains[i], err = parseManipulatorsString(c, chain.Chain); err != nil {
+			return respondWithError(c, batchChainError(chain.Name, err))
+		}
+
+		if chains[i], err = resolvePresets(c, pathConfig, chains[i]); err != nil {
+			return respondWithError(c, batchChainError(chain.Name, err))
+		}
+	}
+
+	img, contentType, err := fetchSourceImage(c, pathConfig.Path, req.Source.URL)
+	if err != nil {
+		return respondWithError(c, err)
+	}
+
+	results := make([]*batchResult, 0, len(chains))
+	for i, actions := range chains {
+		result, err := renderBatchChain(c, pathConfig, req.Chains[i].Name, actions, img, contentType)
+		if err != nil {
+			return respondWithError(c, batchChainError(req.Chains[i].Name, err))
+		}
+		results = append(results, result)
+	}
+
+	setCacheControl(c, pathConfig)
+
+	switch format {
+	case batchFormatMultipart:
+		return writeBatchMultipart(c, results)
+	case batchFormatZip:
+		return writeBatchZip(c, results)
+	}
+
+	return c.JSON(results)
+}
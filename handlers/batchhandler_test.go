@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const batchTestChains = `"chains": [{"name": "small", "chain": "resize:w=20/output:f=png"}, {"name": "large-2x", "chain": "dpr:v=2/resize:w=40/output:f=jpg"}]`
+
+// batchTestImage is the expected result of a batch test chain
+type batchTestImage struct {
+	fileName    string
+	contentType string
+	width       int
+	dpr         string
+}
+
+var batchTestImages = []batchTestImage{
+	{"small.png", "image/png", 20, ""},
+	{"large-2x.jpg", "image/jpeg", 80, "2"},
+}
+
+func checkBatchImage(t *testing.T, expected batchTestImage, contentType string, body []byte) {
+	t.Helper()
+
+	if contentType != expected.contentType {
+		t.Errorf("%s: expected content type %s, got %s", expected.fileName, expected.contentType, contentType)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("%s: failed to decode image: %v", expected.fileName, err)
+	}
+
+	if img.Bounds().Dx() != expected.width {
+		t.Errorf("%s: expected width %d, got %d", expected.fileName, expected.width, img.Bounds().Dx())
+	}
+}
+
+func TestHandleBatch_Formats(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Post("/batch", HandleBatch)
+
+	tests := []struct {
+		name   string
+		format string
+		accept string
+		check  func(t *testing.T, contentType string, body []byte)
+	}{
+		{
+			name:   "json",
+			format: "json",
+			check: func(t *testing.T, contentType string, body []byte) {
+				var results []batchResult
+				if err := json.Unmarshal(body, &results); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if len(results) != len(batchTestImages) {
+					t.Fatalf("Expected %d results, got %d", len(batchTestImages), len(results))
+				}
+				for i, result := range results {
+					checkBatchImage(t, batchTestImages[i], result.ContentType, result.Body)
+					if result.fileName() != batchTestImages[i].fileName {
+						t.Errorf("Expected file name %s, got %s", batchTestImages[i].fileName, result.fileName())
+					}
+					if result.Width != batchTestImages[i].width {
+						t.Errorf("Expected width %d, got %d", batchTestImages[i].width, result.Width)
+					}
+					if result.DPR != batchTestImages[i].dpr {
+						t.Errorf("Expected dpr %q, got %q", batchTestImages[i].dpr, result.DPR)
+					}
+				}
+			},
+		},
+		{
+			name:   "multipart",
+			accept: "multipart/mixed",
+			check: func(t *testing.T, contentType string, body []byte) {
+				mediaType, params, err := mime.ParseMediaType(contentType)
+				if err != nil || mediaType != "multipart/mixed" {
+					t.Fatalf("Expected multipart/mixed response, got %s", contentType)
+				}
+
+				reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+				for _, expected := range batchTestImages {
+					part, err := reader.NextPart()
+					if err != nil {
+						t.Fatalf("Failed to read part %s: %v", expected.fileName, err)
+					}
+					if part.FileName() != expected.fileName {
+						t.Errorf("Expected file name %s, got %s", expected.fileName, part.FileName())
+					}
+					if dpr := part.Header.Get("Content-DPR"); dpr != expected.dpr {
+						t.Errorf("Expected dpr %q, got %q", expected.dpr, dpr)
+					}
+					data, _ := io.ReadAll(part)
+					checkBatchImage(t, expected, part.Header.Get("Content-Type"), data)
+				}
+				if _, err := reader.NextPart(); err != io.EOF {
+					t.Errorf("Expected %d parts", len(batchTestImages))
+				}
+			},
+		},
+		{
+			name:   "zip",
+			format: "zip",
+			check: func(t *testing.T, contentType string, body []byte) {
+				if contentType != "application/zip" {
+					t.Fatalf("Expected application/zip response, got %s", contentType)
+				}
+
+				archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+				if err != nil {
+					t.Fatalf("Failed to open zip: %v", err)
+				}
+				if len(archive.File) != len(batchTestImages) {
+					t.Fatalf("Expected %d files, got %d", len(batchTestImages), len(archive.File))
+				}
+				for i, file := range archive.File {
+					if file.Name != batchTestImages[i].fileName {
+						t.Errorf("Expected file name %s, got %s", batchTestImages[i].fileName, file.Name)
+					}
+					f, err := file.Open()
+					if err != nil {
+						t.Fatalf("Failed to open %s: %v", file.Name, err)
+					}
+					data, _ := io.ReadAll(f)
+					f.Close()
+					checkBatchImage(t, batchTestImages[i], batchTestImages[i].contentType, data)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"source": {"path": "/test", "url": "test.png"}, ` + batchTestChains
+			if tt.format != "" {
+				body += `, "format": "` + tt.format + `"`
+			}
+			body += `}`
+
+			req := httptest.NewRequest("POST", "/batch", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, string(data))
+			}
+
+			tt.check(t, resp.Header.Get("Content-Type"), data)
+		})
+	}
+}
+
+func TestHandleBatch_Errors(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Post("/batch", HandleBatch)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "invalid json",
+			body:           `{"chains": `,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "Invalid batch definition",
+		},
+		{
+			name:           "unknown format",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "chains": [{"name": "a", "chain": "output:f=png"}], "format": "tar"}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "unknown format 'tar'",
+		},
+		{
+			name:           "no chains",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "chains": []}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "between 1 and 16 chains",
+		},
+		{
+			name:           "invalid name",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "chains": [{"name": "../a", "chain": "output:f=png"}]}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "Invalid chain name '../a'",
+		},
+		{
+			name:           "duplicate name",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "chains": [{"name": "a", "chain": "output:f=png"}, {"name": "a", "chain": "output:f=jpg"}]}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "Duplicate chain name 'a'",
+		},
+		{
+			name:           "invalid chain",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "chains": [{"name": "a", "chain": "output:f=png"}, {"name": "b", "chain": "resize:w=abc"}]}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "chain 'b': invalid step 1 'resize:w=abc'",
+		},
+		{
+			name:           "unknown path",
+			body:           `{"source": {"path": "/other", "url": "test.png"}, "chains": [{"name": "a", "chain": "output:f=png"}]}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "No path is configured",
+		},
+		{
+			name:           "missing image",
+			body:           `{"source": {"path": "/test", "url": "missing.png"}, "chains": [{"name": "a", "chain": "output:f=png"}]}`,
+			expectedStatus: fiber.StatusInternalServerError,
+			expectedError:  "Failed to fetch image",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("POST", "/batch", strings.NewReader(tt.body)))
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+
+			if !strings.Contains(string(body), tt.expectedError) {
+				t.Errorf("Expected error to contain %q, got %q", tt.expectedError, string(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/middleware"
+)
+
+// A manipulators chain follows this grammar:
+//
+//	chain  = step *( "/" step )
+//	step   = name [ ":" [ param *( "," param ) ] ]
+//	param  = key [ "=" value ]
+//	value  = *( char / "'" *( char / "''" ) "'" )
+//
+// Example:
+// rotate:a=45,p=5|35/resize:w=405,h=32/output:f=jpg,q=45
+//
+// Only the first ':' of a step and the first '=' of a parameter are delimiters, so values may contain both.
+// A value that contains '/' or ',' can either be quoted with single quotes, where '' stands for a quote
+// (e.g. font='Open Sans, bold'), or percent-encoded (%2F, %2C). Percent-encoded characters are decoded
+// after the chain is split and never act as delimiters.
+//
+// Steps can be made conditional on the current image using if/else/endif steps or a "when" parameter. The
+// parameters of an "if" step are its condition as is:
+// if:w>2000/resize:w=2000/endif/rotate:a=90,when=landscape
+
+// splitUnquoted splits s on sep, ignoring separators inside single quotes
+func splitUnquoted(s string, sep byte) ([]string, error) {
+	var parts []string
+	var quoted bool
+	var start int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+
+	return append(parts, s[start:]), nil
+}
+
+// unquoteValue removes the quoting of a value and decodes its percent-encoded characters. A '%' that
+// doesn't start a valid escape sequence is kept as is, so values such as 10% don't have to be escaped
+func unquoteValue(s string) string {
+	var b strings.Builder
+	var quoted bool
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			if quoted && i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			quoted = !quoted
+		case s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+// isStepName reports whether name is made of lower case letters and digits, starting with a letter
+func isStepName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z') && !(i > 0 && '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// newStepAction validates a step against its schema and returns it as an action
+func newStepAction(name string, params map[string]string, condition string) (*manipulatorAction, error) {
+	if !isStepName(name) {
+		return nil, fmt.Errorf("invalid manipulator name '%s'", name)
+	}
+
+	schema, ok := getStepSchema(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown manipulator '%s'", name)
+	}
+
+	if name != "if" && condition != "" {
+		if err := validateManipulatorParameter("when", condition); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Validate parameter values to prevent attacks
+	for _, key := range keys {
+		if err := validateManipulatorParameter(key, params[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := schema.Validate(params); err != nil {
+		return nil, err
+	}
+	schema.Normalize(params)
+
+	return &manipulatorAction{Name: name, Params: params, Condition: condition}, nil
+}
+
+// parseStep parses a single step of a manipulators chain and validates it against its schema
+func parseStep(step string) (*manipulatorAction, error) {
+	name, paramsString, _ := strings.Cut(step, ":")
+	if name == "if" {
+		return newStepAction(name, map[string]string{}, unquoteValue(paramsString))
+	}
+
+	parts, err := splitUnquoted(paramsString, ',')
+	if err != nil {
+		return nil, err
+	}
+
+	var params = map[string]string{}
+	var condition string
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(part, "=")
+		value = unquoteValue(value)
+		if key == "" {
+			return nil, fmt.Errorf("missing parameter name in '%s'", part)
+		}
+
+		if key == "when" {
+			condition = value
+			continue
+		}
+
+		if _, exists := params[key]; exists {
+			return nil, fmt.Errorf("duplicate parameter %s", key)
+		}
+		params[key] = value
+	}
+
+	return newStepAction(name, params, condition)
+}
+
+// parseManipulatorsString parses a manipulators chain. Errors are request errors pointing to the offending step
+func parseManipulatorsString(c *fiber.Ctx, p string) ([]*manipulatorAction, error) {
+	logger := middleware.GetLoggerFromContext(c)
+
+	steps, err := splitUnquoted(p, '/')
+	if err != nil {
+		return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("invalid manipulators chain: %v", err)}
+	}
+
+	var result []*manipulatorAction
+	for i, step := range steps {
+		// Empty steps, e.g. a trailing '/', are ignored
+		if step == "" {
+			continue
+		}
+
+		logger.Debug().Str("step", step).Msg("Parsing manipulator")
+		action, err := parseStep(step)
+		if err != nil {
+			logger.Warn().Int("step", i+1).Str("value", step).Err(err).Msg("Invalid manipulator step")
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("invalid step %d '%s': %v", i+1, step, err)}
+		}
+
+		result = append(result, action)
+	}
+
+	return result, nil
+}
+
+// escapeValue percent-encodes every character of a value that may act as a delimiter or needs escaping in a URL
+func escapeValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("-._~|;!*()$@+", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalChain serializes a manipulators chain in a canonical form: parameters are sorted by name and values
+// are escaped consistently, so equivalent chains produce the same string. The result is a valid chain that
+// parses back to the same steps
+func canonicalChain(actions []*manipulatorAction) string {
+	var steps []string
+	for _, action := range actions {
+		if action == nil {
+			continue
+		}
+
+		if action.Name == "if" {
+			steps = append(steps, "if:"+escapeValue(action.Condition))
+			continue
+		}
+
+		params := make([]string, 0, len(action.Params)+1)
+		for key, value := range action.Params {
+			params = append(params, key+"="+escapeValue(value))
+		}
+		if action.Condition != "" {
+			params = append(params, "when="+escapeValue(action.Condition))
+		}
+		sort.Strings(params)
+
+		if len(params) == 0 {
+			steps = append(steps, action.Name)
+		} else {
+			steps = append(steps, action.Name+":"+strings.Join(params, ","))
+		}
+	}
+
+	return strings.Join(steps, "/")
+}
+
+// requestCacheKey identifies the result of applying a manipulators chain on a source image. Requests that differ
+// only in the order of parameters or in how values are escaped share the same key
+func requestCacheKey(path string, imageURL string, actions []*manipulatorAction) string {
+	return sourceCacheKey(path, imageURL) + "/" + canonicalChain(actions)
+}
+
+// sourceCacheKey identifies a source image by its path and URL
+func sourceCacheKey(path string, imageURL string) string {
+	return path + "/" + url.PathEscape(imageURL)
+}
+
+// requestETag returns a strong ETag derived from the cache key of the request and the content of its source image
+func requestETag(cacheKey string, source []byte) string {
+	h := sha1.New()
+	h.Write([]byte(cacheKey))
+	h.Write([]byte{0})
+	h.Write(source)
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
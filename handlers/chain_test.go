@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseTestChain parses a chain step by step, as parseManipulatorsString does without requiring a request context
+func parseTestChain(t *testing.T, chain string) []*manipulatorAction {
+	steps, err := splitUnquoted(chain, '/')
+	if err != nil {
+		t.Fatalf("splitUnquoted(%q) error = %v", chain, err)
+	}
+
+	var actions []*manipulatorAction
+	for _, step := range steps {
+		if step == "" {
+			continue
+		}
+		action, err := parseStep(step)
+		if err != nil {
+			t.Fatalf("parseStep(%q) error = %v", step, err)
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+func TestParseStep(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	tests := []struct {
+		name       string
+		step       string
+		wantParams map[string]string
+		wantCond   string
+		wantErr    string
+	}{
+		{"simple", "resize:w=40,h=30", map[string]string{"w": "40", "h": "30"}, "", ""},
+		{"no parameters", "circle", map[string]string{}, "", ""},
+		{"empty parameters", "circle:", map[string]string{}, "", ""},
+		{"colon in value", "paste:img=local:logo.png", map[string]string{"img": "local:logo.png"}, "", ""},
+		{"quoted comma and slash", "paste:img='local:a,b/c.png',x=5", map[string]string{"img": "local:a,b/c.png", "x": "5"}, "", ""},
+		{"escaped quote", "paste:img='it''s.png'", map[string]string{"img": "it's.png"}, "", ""},
+		{"percent encoded delimiters", "paste:img=local:a%2Cb%2Fc.png", map[string]string{"img": "local:a,b/c.png"}, "", ""},
+		{"literal percent", "pad:t=10%", map[string]string{"t": "10%"}, "", ""},
+		{"pad right and bottom", "pad:r=10%,b=300", map[string]string{"r": "10%", "b": "300"}, "", ""},
+		{"padding out of range", "pad:p=1000000%", nil, "", "parameter p value 1e+06 is outside valid range"},
+		{"when condition", "rotate:a=90,when=w%3E50", map[string]string{"a": "90"}, "w>50", ""},
+		{"if condition", "if:w%3E50,portrait", map[string]string{}, "w>50,portrait", ""},
+		{"unknown manipulator", "blur:r=2", nil, "", "unknown manipulator 'blur'"},
+		{"invalid name", "Resize:w=40", nil, "", "invalid manipulator name"},
+		{"missing parameter name", "resize:=40", nil, "", "missing parameter name"},
+		{"duplicate parameter", "resize:w=40,w=50", nil, "", "duplicate parameter w"},
+		{"unterminated quote", "paste:img='logo.png", nil, "", "unterminated quote"},
+		{"invalid value", "resize:w=abc", nil, "", "parameter w requires a numeric value"},
+		{"unknown parameter", "resize:w=40,z=1", nil, "", "unknown parameter z"},
+		{"negative shadow offset", "shadow:x=-10,y=-5", map[string]string{"x": "-10", "y": "-5"}, "", ""},
+		{"shadow offset out of range", "shadow:x=100000000", nil, "", "parameter x value 1e+08 is outside valid range"},
+		{"scale out of range", "resize:scale=100000%", nil, "", "parameter scale value 100000 is outside valid range"},
+		{"boolean normalized", "resize:w=40,upscale=false", map[string]string{"w": "40", "upscale": "0"}, "", ""},
+		{"unbounded pixel value", "border:w=10,r=100000000", nil, "", "parameter r value 1e+08 is outside valid range"},
+		{"NaN value", "effect:name=dilate,r=NaN", nil, "", "parameter r requires a finite number"},
+		{"infinite value", "shearh:a=-Inf", nil, "", "parameter a requires a finite number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := splitUnquoted(tt.step, '/')
+			if err == nil && len(parts) != 1 {
+				t.Fatalf("expected a single step, got %v", parts)
+			}
+			if err == nil {
+				var action *manipulatorAction
+				if action, err = parseStep(parts[0]); err == nil {
+					if tt.wantErr != "" {
+						t.Fatalf("expected error %q", tt.wantErr)
+					}
+					if !reflect.DeepEqual(action.Params, tt.wantParams) {
+						t.Errorf("params = %v, want %v", action.Params, tt.wantParams)
+					}
+					if action.Condition != tt.wantCond {
+						t.Errorf("condition = %q, want %q", action.Condition, tt.wantCond)
+					}
+					return
+				}
+			}
+
+			if tt.wantErr == "" || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanonicalChain(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	equivalent := []string{
+		"resize:w=40,h=30/paste:img=local:a%2Cb.png,x=5/output:f=jpg,q=80",
+		"resize:h=30,w=40//paste:x=5,img='local:a,b.png'/output:q=80,f=jpg/",
+		"resize:h=30,w=40/paste:img=local%3Aa%2cb.png,x=5/output:q=80,f=jpg",
+	}
+
+	canonical := canonicalChain(parseTestChain(t, equivalent[0]))
+	for _, chain := range equivalent[1:] {
+		if got := canonicalChain(parseTestChain(t, chain)); got != canonical {
+			t.Errorf("canonicalChain(%q) = %q, want %q", chain, got, canonical)
+		}
+	}
+
+	// The canonical form parses back to the same steps
+	chains := append(equivalent, "if:w%3E50,portrait/rotate:a=90/else/flipv:when=square/endif", "paste:img='it''s 100%.png'")
+	for _, chain := range chains {
+		actions := parseTestChain(t, chain)
+		if got := parseTestChain(t, canonicalChain(actions)); !reflect.DeepEqual(got, actions) {
+			t.Errorf("round trip of %q = %v, want %v", chain, got, actions)
+		}
+	}
+}
+
+func TestHandleImage_ChainErrors(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	tests := []struct {
+		name        string
+		url         string
+		wantMessage string
+	}{
+		{"invalid value", "/test/test.png/resize:w=40/rotate:a=abc/output:f=png", "invalid step 2 'rotate:a=abc'"},
+		{"unknown manipulator", "/test/test.png/resize:w=40/output:f=png/blur:r=2", "invalid step 3 'blur:r=2': unknown manipulator 'blur'"},
+		{"unterminated quote", "/test/test.png/paste:img='a.png", "unterminated quote"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.url, nil))
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Fatalf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+			if !strings.Contains(string(body), tt.wantMessage) {
+				t.Errorf("Expected message to contain %q, got %q", tt.wantMessage, string(body))
+			}
+		})
+	}
+}
+
+func TestHandleImage_ETag(t *testing.T) {
+	tempDir, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	get := func(url string, ifNoneMatch string) (int, string) {
+		req := httptest.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to perform request: %v", err)
+		}
+		return resp.StatusCode, resp.Header.Get("ETag")
+	}
+
+	status, etag := get("/test/test.png/resize:w=40,h=30/output:f=png", "")
+	if status != fiber.StatusOK || etag == "" {
+		t.Fatalf("Expected status 200 with an ETag, got %d %q", status, etag)
+	}
+
+	if _, other := get("/test/test.png/resize:h=30,w=40/output:f=png/", ""); other != etag {
+		t.Errorf("Expected equivalent chains to share the ETag %q, got %q", etag, other)
+	}
+
+	if _, other := get("/test/test.png/resize:w=41,h=30/output:f=png", ""); other == etag {
+		t.Errorf("Expected a different chain to have a different ETag")
+	}
+
+	if status, _ := get("/test/test.png/resize:h=30,w=40/output:f=png", etag); status != fiber.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", fiber.StatusNotModified, status)
+	}
+
+	// A changed source image must not be reported as not modified
+	pngData, err := createTestImage(50, 50, "png")
+	if err != nil {
+		t.Fatalf("Failed to create test PNG: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "test.png"), pngData, 0644); err != nil {
+		t.Fatalf("Failed to write test PNG: %v", err)
+	}
+
+	status, other := get("/test/test.png/resize:w=40,h=30/output:f=png", etag)
+	if status != fiber.StatusOK || other == etag {
+		t.Errorf("Expected status 200 with a new ETag for a changed source, got %d %q", status, other)
+	}
+}
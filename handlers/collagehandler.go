@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"image"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/config"
+	"github.com/erans/thumbla/manipulators"
+	"github.com/erans/thumbla/middleware"
+)
+
+// resolveSourceReference splits a source reference in the form of <configured path>/<escaped url>[/<manipulators>]
+// into the matching path config, the image URL and the manipulators string
+func resolveSourceReference(ref string) (*config.PathConfig, string, string, error) {
+	var pathConfig *config.PathConfig
+	var prefix string
+
+	cfg := config.GetConfig()
+	for i, p := range cfg.Paths {
+		candidate := strings.TrimSuffix(p.Path, "/") + "/"
+		// Prefer the longest matching path
+		if strings.HasPrefix(ref, candidate) && len(candidate) > len(prefix) {
+			pathConfig = &cfg.Paths[i]
+			prefix = candidate
+		}
+	}
+
+	if pathConfig == nil {
+		return nil, "", "", &requestError{fiber.StatusBadRequest, fmt.Sprintf("No path is configured for source '%s'", ref)}
+	}
+
+	rest := strings.TrimPrefix(ref, prefix)
+	escapedURL, chain, _ := strings.Cut(rest, "/")
+
+	imageURL, err := url.QueryUnescape(escapedURL)
+	if err != nil || imageURL == "" {
+		return nil, "", "", &requestError{fiber.StatusBadRequest, fmt.Sprintf("Invalid URL passed in source '%s'. Have you tried URL escaping it?", ref)}
+	}
+
+	return pathConfig, imageURL, chain, nil
+}
+
+// loadSourceReference fetches the image of a source reference and applies its own manipulators chain
+func loadSourceReference(c *fiber.Ctx, ref string) (image.Image, error) {
+	pathConfig, imageURL, chain, err := resolveSourceReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := parseManipulatorsString(c, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	if actions, err = resolvePresets(c, pathConfig, actions); err != nil {
+		return nil, err
+	}
+
+	img, _, err := fetchSourceImage(c, pathConfig.Path, imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyManipulators(c, actions, img)
+}
+
+// HandleCollage composes multiple source images into a grid or a mosaic and applies the manipulators chain
+// given in the URL on the result.
+//
+// Every "src" query parameter is a reference in the form of <configured path>/<escaped url>[/<manipulators>],
+// the other query parameters control the layout (see manipulators.ParseCollageOptions)
+func HandleCollage(c *fiber.Ctx) error {
+	logger := middleware.GetLoggerFromContext(c)
+	logger.Debug().Str("path", c.Path()).Msg("Handling collage request")
+
+	cfg := config.GetConfig()
+
+	sources := c.Context().QueryArgs().PeekMulti("src")
+	if len(sources) == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("At least one source (src) is required")
+	}
+
+	if len(sources) > cfg.GetMaxCollageSources() {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Too many sources. A collage supports up to %d images", cfg.GetMaxCollageSources()))
+	}
+
+	params := map[string]string{}
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		if string(key) != "src" {
+			params[string(key)] = string(value)
+		}
+	})
+
+	opts, err := manipulators.ParseCollageOptions(params, len(sources))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	maxDimension := cfg.GetMaxImageDimension()
+	if opts.Width > maxDimension || opts.Height > maxDimension {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("collage dimensions exceed maximum allowed size (%dx%d)", maxDimension, maxDimension))
+	}
+	opts.MaxDimension = maxDimension
+
+	images := make([]image.Image, 0, len(sources))
+	for _, src := range sources {
+		logger.Debug().Str("src", string(src)).Msg("Loading collage source")
+		img, err := loadSourceReference(c, string(src))
+		if err != nil {
+			return respondWithError(c, err)
+		}
+		images = append(images, img)
+	}
+
+	// Output settings of the source chains must not affect the collage itself
+	resetOutputHeaders(c)
+
+	img, err := manipulators.ComposeCollage(images, opts)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	actions, err := parseManipulators(c)
+	if err != nil {
+		return respondWithError(c, err)
+	}
+
+	if actions, err = resolvePresets(c, nil, actions); err != nil {
+		return respondWithError(c, err)
+	}
+
+	if img, err = applyManipulators(c, actions, img); err != nil {
+		return respondWithError(c, err)
+	}
+
+	return sendImage(c, nil, "image/jpeg", img)
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"image/png"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/config"
+)
+
+func TestHandleCollage(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Get("/collage/*", HandleCollage)
+
+	tests := []struct {
+		name           string
+		query          url.Values
+		chain          string
+		expectedStatus int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{
+			name: "2x2 grid",
+			query: url.Values{
+				"src": {"/test/test.jpg", "/test/test.png", "/test/test.jpg/rotate:a=90", "/test/test.png/flipv:"},
+				"w":   {"200"},
+				"gap": {"10"},
+			},
+			chain:          "output:f=png",
+			expectedStatus: fiber.StatusOK,
+			expectedWidth:  200,
+			expectedHeight: 200,
+		},
+		{
+			name: "mosaic with source manipulators",
+			query: url.Values{
+				"src":    {"/test/test.jpg/crop:x=0,y=0,w=100,h=50", "/test/test.png"},
+				"layout": {"mosaic"},
+				"rows":   {"1"},
+				"w":      {"300"},
+			},
+			chain:          "output:f=png",
+			expectedStatus: fiber.StatusOK,
+			expectedWidth:  300,
+			expectedHeight: 100,
+		},
+		{
+			name: "rows capped to the number of sources",
+			query: url.Values{
+				"src":  {"/test/test.png"},
+				"cols": {"1"},
+				"rows": {"100000"},
+				"w":    {"100"},
+			},
+			chain:          "output:f=png",
+			expectedStatus: fiber.StatusOK,
+			expectedWidth:  100,
+			expectedHeight: 100,
+		},
+		{
+			name: "cover cell of a source far narrower than the cell",
+			query: url.Values{
+				"src": {"/test/test.png/crop:x=0,y=0,w=1,h=100"},
+				"w":   {"1000"},
+			},
+			chain:          "output:f=png",
+			expectedStatus: fiber.StatusOK,
+			expectedWidth:  1000,
+			expectedHeight: 1000,
+		},
+		{
+			name: "mosaic taller than the max dimension",
+			query: url.Values{
+				"src":    {"/test/test.png/crop:x=0,y=0,w=1,h=100"},
+				"layout": {"mosaic"},
+				"w":      {"1000"},
+			},
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "missing sources",
+			query:          url.Values{"w": {"200"}},
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "source on an unknown path",
+			query:          url.Values{"src": {"/unknown/test.jpg"}},
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "missing source image",
+			query:          url.Values{"src": {"/test/missing.jpg"}},
+			expectedStatus: fiber.StatusInternalServerError,
+		},
+		{
+			name:           "unknown layout",
+			query:          url.Values{"src": {"/test/test.jpg"}, "layout": {"spiral"}},
+			expectedStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/collage/"+tt.chain+"?"+tt.query.Encode(), nil)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			img, err := png.Decode(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if img.Bounds().Dx() != tt.expectedWidth || img.Bounds().Dy() != tt.expectedHeight {
+				t.Errorf("Expected %dx%d collage, got %v", tt.expectedWidth, tt.expectedHeight, img.Bounds())
+			}
+		})
+	}
+}
+
+func TestHandleCollage_PresetsOnly(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	cfg := config.GetConfig()
+	cfg.Presets = map[string]string{"thumb": "resize:w=40"}
+	cfg.Paths[0].PresetsOnly = true
+	defer func() {
+		cfg.Presets = nil
+		cfg.Paths[0].PresetsOnly = false
+	}()
+
+	app := fiber.New()
+	app.Get("/collage/*", HandleCollage)
+
+	tests := []struct {
+		name           string
+		src            string
+		expectedStatus int
+	}{
+		{"preset", "/test/test.jpg/preset:name=thumb", fiber.StatusOK},
+		{"no chain", "/test/test.jpg", fiber.StatusOK},
+		{"arbitrary steps", "/test/test.jpg/resize:w=50/rotate:a=33", fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{"src": {tt.src}, "w": {"100"}}
+			resp, err := app.Test(httptest.NewRequest("GET", "/collage/output:f=png?"+query.Encode(), nil))
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/erans/thumbla/manipulators"
+)
+
+// Operators are listed longest first so that ">=" is not mistaken for ">"
+var conditionOperators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// conditionFlags are the boolean properties a condition can test
+var conditionFlags = map[string]func(img image.Image) bool{
+	"portrait":  func(img image.Image) bool { return img.Bounds().Dy() > img.Bounds().Dx() },
+	"landscape": func(img image.Image) bool { return img.Bounds().Dx() > img.Bounds().Dy() },
+	"square":    func(img image.Image) bool { return img.Bounds().Dx() == img.Bounds().Dy() },
+	"alpha":     func(img image.Image) bool { return !manipulators.IsOpaque(img) },
+	"opaque":    manipulators.IsOpaque,
+}
+
+// conditionOperands are the numeric properties a condition can compare
+var conditionOperands = map[string]func(img image.Image) float64{
+	"w":  func(img image.Image) float64 { return float64(img.Bounds().Dx()) },
+	"h":  func(img image.Image) float64 { return float64(img.Bounds().Dy()) },
+	"ar": func(img image.Image) float64 { return float64(img.Bounds().Dx()) / float64(img.Bounds().Dy()) },
+	"px": func(img image.Image) float64 { return float64(img.Bounds().Dx()) * float64(img.Bounds().Dy()) },
+}
+
+// evaluateCondition evaluates a condition against the current image. A condition is a list of clauses separated
+// by ',' or '&' that must all be true. A clause is either a flag (portrait, landscape, square, alpha, opaque),
+// optionally negated with '!', or a comparison of w, h, ar (aspect ratio) or px (total pixels) with a number,
+// e.g. "w>2000&landscape"
+func evaluateCondition(condition string, img image.Image) (bool, error) {
+	clauses := strings.FieldsFunc(condition, func(r rune) bool { return r == ',' || r == '&' })
+	if len(clauses) == 0 {
+		return false, fmt.Errorf("empty condition")
+	}
+
+	for _, clause := range clauses {
+		result, err := evaluateClause(clause, img)
+		if err != nil {
+			return false, err
+		}
+		if !result {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// evaluateClause evaluates a single flag or comparison
+func evaluateClause(clause string, img image.Image) (bool, error) {
+	flag := strings.TrimPrefix(clause, "!")
+	if f, ok := conditionFlags[flag]; ok {
+		return f(img) != (flag != clause), nil
+	}
+
+	for _, op := range conditionOperators {
+		name, value, found := strings.Cut(clause, op)
+		if !found {
+			continue
+		}
+
+		operand, ok := conditionOperands[name]
+		if !ok {
+			return false, fmt.Errorf("unknown condition operand '%s'", name)
+		}
+
+		expected, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid condition value '%s'", value)
+		}
+
+		actual := operand(img)
+		switch op {
+		case ">=":
+			return actual >= expected, nil
+		case "<=":
+			return actual <= expected, nil
+		case "!=":
+			return actual != expected, nil
+		case ">":
+			return actual > expected, nil
+		case "<":
+			return actual < expected, nil
+		default:
+			return actual == expected, nil
+		}
+	}
+
+	return false, fmt.Errorf("invalid condition '%s'", clause)
+}
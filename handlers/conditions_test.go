@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"image"
+	"image/png"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	landscape := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for i := 3; i < len(landscape.Pix); i += 4 {
+		landscape.Pix[i] = 255
+	}
+	transparent := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+
+	tests := []struct {
+		condition string
+		img       image.Image
+		want      bool
+		wantErr   bool
+	}{
+		{"w>200", landscape, true, false},
+		{"w>=300", landscape, true, false},
+		{"w>300", landscape, false, false},
+		{"h<=200", landscape, true, false},
+		{"h==200", landscape, true, false},
+		{"h=200", landscape, true, false},
+		{"w!=300", landscape, false, false},
+		{"ar>1.4", landscape, true, false},
+		{"px<60000", landscape, false, false},
+		{"landscape", landscape, true, false},
+		{"portrait", landscape, false, false},
+		{"!portrait", landscape, true, false},
+		{"opaque", landscape, true, false},
+		{"alpha", transparent, true, false},
+		{"square&alpha", transparent, true, false},
+		{"w>200,portrait", landscape, false, false},
+		{"", landscape, false, true},
+		{"d>10", landscape, false, true},
+		{"w>abc", landscape, false, true},
+		{"round", landscape, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			got, err := evaluateCondition(tt.condition, tt.img)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("evaluateCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("evaluateCondition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleImage_Conditions(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedWidth  int
+	}{
+		{"if matched", "/test/test.png/if:w%3E50/resize:w=40/endif/output:f=png", fiber.StatusOK, 40},
+		{"if not matched", "/test/test.png/if:w%3E500/resize:w=40/endif/output:f=png", fiber.StatusOK, 100},
+		{"else branch", "/test/test.png/if:portrait/resize:w=40/else/resize:w=30/endif/output:f=png", fiber.StatusOK, 30},
+		{"nested blocks", "/test/test.png/if:square/if:w%3C50/resize:w=10/else/resize:w=20/endif/endif/output:f=png", fiber.StatusOK, 20},
+		{"conditions use the current image", "/test/test.png/resize:w=40/if:w%3E50/resize:w=10/endif/output:f=png", fiber.StatusOK, 40},
+		{"when matched", "/test/test.png/resize:w=40,when=w%3E%3D100/output:f=png", fiber.StatusOK, 40},
+		{"when not matched", "/test/test.png/resize:w=40,when=landscape/output:f=png", fiber.StatusOK, 100},
+		{"missing endif", "/test/test.png/if:square/resize:w=40/output:f=png", fiber.StatusBadRequest, 0},
+		{"endif without if", "/test/test.png/endif/output:f=png", fiber.StatusBadRequest, 0},
+		{"invalid condition", "/test/test.png/if:depth%3E2/endif/output:f=png", fiber.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			img, err := png.Decode(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if img.Bounds().Dx() != tt.expectedWidth {
+				t.Errorf("Expected width %d, got %d", tt.expectedWidth, img.Bounds().Dx())
+			}
+		})
+	}
+}
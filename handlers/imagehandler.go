@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
@@ -28,9 +30,15 @@ import (
 	"github.com/erans/thumbla/utils"
 )
 
+// sourceKey is the context key of the cache key of the source image, set by handlers whose manipulators may
+// cache results derived from the image
+const sourceKey = "sourceKey"
+
 type manipulatorAction struct {
 	Name   string
 	Params map[string]string
+	// Condition is the condition of an "if" step or the "when" parameter of any other step
+	Condition string
 }
 
 func loadImage(c *fiber.Ctx, url string, contentType string, body io.Reader, alternativeWidth int, alternativeHeight int) (image.Image, error) {
@@ -58,7 +66,9 @@ func loadImage(c *fiber.Ctx, url string, contentType string, body io.Reader, alt
 		img, err = gif.Decode(body)
 	} else if contentType == "image/svg+xml" {
 		var svgImg *oksvg.SvgIcon
-		svgImg, err = oksvg.ReadIconStream(body)
+		if svgImg, err = oksvg.ReadIconStream(body); err != nil {
+			return nil, err
+		}
 		w := int(svgImg.ViewBox.W)
 		h := int(svgImg.ViewBox.H)
 
@@ -86,6 +96,12 @@ func loadImage(c *fiber.Ctx, url string, contentType string, body io.Reader, alt
 			w = alternativeWidth
 		}
 
+		// The size comes from the document or the URL, so it is validated before the raster is allocated
+		maxDimension := config.GetConfig().GetMaxImageDimension()
+		if w < 1 || h < 1 || w > maxDimension || h > maxDimension {
+			return nil, fmt.Errorf("svg dimensions (%dx%d) are out of the allowed range (1x1-%dx%d)", w, h, maxDimension, maxDimension)
+		}
+
 		svgImg.SetTarget(0, 0, float64(w), float64(h))
 
 		var tempImg *image.RGBA64 = image.NewRGBA64(image.Rect(0, 0, w, h))
@@ -128,7 +144,8 @@ func loadImage(c *fiber.Ctx, url string, contentType string, body io.Reader, alt
 	return img, nil
 }
 
-// validateManipulatorParameter validates manipulator parameter values
+// validateManipulatorParameter validates the size of manipulator parameters. Values are validated against the
+// schema of each step by getStepSchema
 func validateManipulatorParameter(paramName, paramValue string) error {
 	// Validate parameter name is not too long (prevent memory exhaustion)
 	if len(paramName) > 50 {
@@ -140,162 +157,55 @@ func validateManipulatorParameter(paramName, paramValue string) error {
 		return fmt.Errorf("parameter value too long: %d characters", len(paramValue))
 	}
 
-	// Check for numeric parameters and validate ranges
-	if paramValue != "" {
-		// Common numeric parameters that should have reasonable bounds
-		numericParams := map[string]struct {
-			min, max float64
-		}{
-			"w":       {1, 20000},      // width: 1px to 20,000px
-			"h":       {1, 20000},      // height: 1px to 20,000px
-			"q":       {1, 100},        // quality: 1% to 100%
-			"a":       {-360, 360},     // angle: -360° to 360°
-			"x":       {0, 20000},      // x coordinate: 0 to 20,000px
-			"y":       {0, 20000},      // y coordinate: 0 to 20,000px
-			"r":       {0, 255},        // RGB values: 0 to 255
-			"g":       {0, 255},        // RGB values: 0 to 255
-			"b":       {0, 255},        // RGB values: 0 to 255
-			"a_color": {0, 255},        // Alpha: 0 to 255
-		}
-
-		if bounds, isNumeric := numericParams[paramName]; isNumeric {
-			if val, err := strconv.ParseFloat(paramValue, 64); err == nil {
-				if val < bounds.min || val > bounds.max {
-					return fmt.Errorf("parameter %s value %g is outside valid range [%g, %g]",
-						paramName, val, bounds.min, bounds.max)
-				}
-			} else {
-				return fmt.Errorf("parameter %s requires numeric value, got: %s", paramName, paramValue)
-			}
-		}
-
-		// Validate format parameter has only allowed values
-		if paramName == "f" {
-			allowedFormats := map[string]bool{
-				"jpg":  true,
-				"jpeg": true,
-				"png":  true,
-				"webp": true,
-				"gif":  true,
-			}
-			if !allowedFormats[strings.ToLower(paramValue)] {
-				return fmt.Errorf("unsupported format: %s", paramValue)
-			}
-		}
-
-		// Validate boolean parameters
-		if paramName == "lossless" || paramName == "progressive" {
-			if paramValue != "true" && paramValue != "false" && paramValue != "1" && paramValue != "0" {
-				return fmt.Errorf("parameter %s requires boolean value (true/false/1/0), got: %s",
-					paramName, paramValue)
-			}
-		}
-	}
-
 	return nil
 }
 
-func parseManipulators(c *fiber.Ctx) []*manipulatorAction {
-	// Split / different manipulators
-	// Split : manipulator name + params
-	// Split , manipulator params
-	// Split = manipulator param name + value
-	//
-	// Example:
-	// rotate:a=45,p=5|35/resize:w=405,h=32/output:f=jpg,q=45
-	var result []*manipulatorAction
-	var err error
-	var p = c.Params("*")
-
-	// There are no manipulators on the URL
-	if p == "" {
-		return nil
+func parseManipulators(c *fiber.Ctx) ([]*manipulatorAction, error) {
+	actions, err := parseManipulatorsString(c, c.Params("*"))
+	if err != nil {
+		return nil, err
 	}
 
-	var manipulatorsString = strings.Split(p, "/")
-
-	result = make([]*manipulatorAction, len(manipulatorsString))
-	for k, v := range manipulatorsString {
-		parts := strings.Split(v, ":")
-		var manipulatorName string
-		var manipulatorParamsString string
-		if len(parts) > 0 {
-			manipulatorName = parts[0]
-		}
-
-		logger := middleware.GetLoggerFromContext(c)
-	logger.Debug().Str("manipulator", manipulatorName).Msg("Processing manipulator")
-
-		if len(parts) > 1 {
-			manipulatorParamsString = parts[1]
-		}
-
-		var manipulatorParams = map[string]string{}
-		logger.Debug().Str("params", manipulatorParamsString).Msg("Parsing manipulator parameters")
-		for _, v := range strings.Split(manipulatorParamsString, ",") {
-			var manipulatorParamName string
-			var manipulatorParamValue string
-
-			manipulatorParamsParts := strings.Split(v, "=")
-			if len(manipulatorParamsParts) > 0 {
-				manipulatorParamName = manipulatorParamsParts[0]
-			}
-
-			if len(manipulatorParamsParts) > 1 {
-				manipulatorParamValue = manipulatorParamsParts[1]
-				if manipulatorParamValue, err = url.QueryUnescape(manipulatorParamValue); err != nil {
-					return nil
-				}
-			}
-
-			logger.Debug().Str("param", manipulatorParamName).Str("value", manipulatorParamValue).Msg("Parsed parameter")
-
-			if manipulatorParamName != "" {
-				// Validate parameter values to prevent attacks
-				if err := validateManipulatorParameter(manipulatorParamName, manipulatorParamValue); err != nil {
-					logger.Warn().
-						Str("param", manipulatorParamName).
-						Str("value", manipulatorParamValue).
-						Err(err).
-						Msg("Invalid manipulator parameter")
-					return nil
-				}
-				manipulatorParams[manipulatorParamName] = manipulatorParamValue
-			}
-		}
-
-		if manipulatorName != "" {
-			result[k] = &manipulatorAction{manipulatorName, manipulatorParams}
-		}
+	// A dpr query parameter acts as a dpr step at the beginning of the chain
+	if dpr := c.Query("dpr"); dpr != "" {
+		actions = append([]*manipulatorAction{{Name: "dpr", Params: map[string]string{"v": dpr}}}, actions...)
 	}
 
-	return result
+	return actions, nil
 }
 
-func writeImageToResponse(c *fiber.Ctx, contentType string, img image.Image) error {
+func writeImageToResponse(c *fiber.Ctx, contentType string, img image.Image, background color.Color) error {
+	return encodeImage(c, c.Response().BodyWriter(), contentType, img, background)
+}
 
+// encodeImage encodes the image in the given format, using the encoder options set by the output manipulator
+// in the response headers
+func encodeImage(c *fiber.Ctx, w io.Writer, contentType string, img image.Image, background color.Color) error {
 	if contentType == "image/jpeg" || contentType == "image/jpg" {
 		var quality = 90
-		var tempQuality = c.Get("X-Quality")
+		var tempQuality = c.GetRespHeader("X-Quality")
 		if tempQuality != "" {
 			quality, _ = strconv.Atoi(tempQuality)
 		}
 
 		c.Response().Header.Del("X-Quality")
 
-		var encoder = c.Get("X-Encoder")
+		var encoder = c.GetRespHeader("X-Encoder")
 		if encoder == "" {
 			encoder = "jpeg"
 		}
 
+		// JPEG has no alpha channel, composite transparent images onto a background
+		img = manipulators.FlattenImage(img, background)
+
 		if encoder == "jpeg" {
-			jpeg.Encode(c.Response().BodyWriter(), img, &jpeg.Options{Quality: quality})
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
 		}
 	} else if contentType == "image/png" {
-		png.Encode(c.Response().BodyWriter(), img)
+		return png.Encode(w, img)
 	} else if contentType == "image/webp" {
 		var quality = 100.0
-		var tempQuality = c.Get("X-Quality")
+		var tempQuality = c.GetRespHeader("X-Quality")
 		if tempQuality != "" {
 			quality, _ = strconv.ParseFloat(tempQuality, 32)
 		}
@@ -306,16 +216,16 @@ func writeImageToResponse(c *fiber.Ctx, contentType string, img image.Image) err
 			return fmt.Errorf("failed to create WebP encoder options: %w", err)
 		}
 
-		var temp = c.Get("X-Lossless")
+		var temp = c.GetRespHeader("X-Lossless")
 		if temp != "" && (temp == "1" || temp == "true") {
 			options.Lossless = true
 		}
 
-		temp = c.Get("X-Exact")
+		temp = c.GetRespHeader("X-Exact")
 		if temp != "" && (temp == "1" || temp == "true") {
 			options.Exact = 1
 		}
-		kolesawebp.Encode(c.Response().BodyWriter(), img, options)
+		return kolesawebp.Encode(w, img, options)
 	} else {
 		return fmt.Errorf("write image to response failed. Unknown content type '%s'", contentType)
 	}
@@ -334,34 +244,42 @@ func getFileParams(imageURL string) (string, []string) {
 	return parts[0], params
 }
 
-// HandleImage is the image handler
-func HandleImage(c *fiber.Ctx) error {
-	logger := middleware.GetLoggerFromContext(c)
-	logger.Debug().Str("path", c.Path()).Msg("Handling image request")
+// requestError is returned by the image pipeline helpers and carries the HTTP status to respond with
+type requestError struct {
+	Status  int
+	Message string
+}
 
-	pathConfig := config.GetConfig().GetPathConfigByPath(c.Route().Path)
+func (e *requestError) Error() string {
+	return e.Message
+}
 
-	var imageURL string
-	var err error
-	imageURL, err = url.QueryUnescape(c.Params("url"))
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).SendString("Invalid URL passed. Have you tried URL escaping it?")
+// respondWithError writes the error as the response, using the status of a requestError when available
+func respondWithError(c *fiber.Ctx, err error) error {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return c.Status(reqErr.Status).SendString(reqErr.Message)
 	}
-	logger.Debug().Str("imageURL", imageURL).Msg("Decoded image URL")
 
-	var parsedURL *url.URL
-	if parsedURL, err = url.Parse(imageURL); err != nil {
-		return c.Status(fiber.StatusBadRequest).SendString("Unable to parse passed URL")
-	}
+	return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+}
 
-	logger.Debug().Str("routePath", c.Route().Path).Msg("Searching for fetcher")
-	logger.Debug().Str("parsedURL", parsedURL.String()).Msg("Parsed URL")
+// sourceImage is a fetched source image that was not decoded yet
+type sourceImage struct {
+	imageURL        string
+	contentType     string
+	body            []byte
+	alternateWidth  int
+	alternateHeight int
+}
 
-	rawRequestPath := c.Route().Path
-	path := rawRequestPath[0:strings.Index(rawRequestPath, "/:url")]
+// fetchSource fetches the image using the fetcher configured for path
+func fetchSource(c *fiber.Ctx, path string, imageURL string) (*sourceImage, error) {
+	logger := middleware.GetLoggerFromContext(c)
 
 	var imageBody io.Reader
 	var contentType string
+	var err error
 
 	var alternateWidth int = -1
 	var alternateHeight int = -1
@@ -370,7 +288,7 @@ func HandleImage(c *fiber.Ctx) error {
 
 	imageURL, params = getFileParams(imageURL)
 
-	if strings.HasSuffix(strings.ToLower(imageURL), ".svg") {
+	if strings.HasSuffix(strings.ToLower(imageURL), ".svg") && len(params) > 1 {
 		alternateWidth, _ = strconv.Atoi(params[0])
 		alternateHeight, _ = strconv.Atoi(params[1])
 	} else if imageURL == "_blank" {
@@ -382,7 +300,7 @@ func HandleImage(c *fiber.Ctx) error {
 			buf := new(bytes.Buffer)
 			err := png.Encode(buf, img)
 			if err != nil {
-				return c.Status(fiber.StatusBadRequest).SendString("failed to create blank image")
+				return nil, &requestError{fiber.StatusBadRequest, "failed to create blank image"}
 			}
 
 			imageBody = buf
@@ -395,45 +313,175 @@ func HandleImage(c *fiber.Ctx) error {
 		fetcher := fetchers.GetFetcherByPath(path)
 		if fetcher == nil {
 			logger.Error().Str("path", path).Msg("No fetcher defined for path")
-			return c.Status(fiber.StatusBadRequest).SendString("No fetcher is defined for specified path")
+			return nil, &requestError{fiber.StatusBadRequest, "No fetcher is defined for specified path"}
 		}
 
 		if imageBody, contentType, err = fetcher.Fetch(c, imageURL); err != nil {
 			logger.Error().Err(err).Str("imageURL", imageURL).Msg("Failed to fetch image")
-			return c.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Failed to fetch image. url=%s", imageURL))
+			return nil, &requestError{fiber.StatusInternalServerError, fmt.Sprintf("Failed to fetch image. url=%s", imageURL)}
 		}
 	}
 
 	if imageBody == nil {
-		return c.Status(fiber.StatusNotFound).SendString("file not found")
+		return nil, &requestError{fiber.StatusNotFound, "file not found"}
 	}
 
 	logger.Debug().Str("contentType", contentType).Str("imageURL", imageURL).Msg("Image fetched successfully")
 
-	var img image.Image
-	if img, err = loadImage(c, imageURL, contentType, imageBody, alternateWidth, alternateHeight); err != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("failed to load fetched image. url=%s", imageURL))
+	body, err := io.ReadAll(imageBody)
+	if err != nil {
+		return nil, &requestError{fiber.StatusInternalServerError, fmt.Sprintf("Failed to read image. url=%s", imageURL)}
+	}
+
+	return &sourceImage{imageURL, contentType, body, alternateWidth, alternateHeight}, nil
+}
+
+// decode decodes the source image and returns it with its content type
+func (s *sourceImage) decode(c *fiber.Ctx) (image.Image, string, error) {
+	img, err := loadImage(c, s.imageURL, s.contentType, bytes.NewReader(s.body), s.alternateWidth, s.alternateHeight)
+	if err != nil {
+		return nil, "", &requestError{fiber.StatusInternalServerError, fmt.Sprintf("failed to load fetched image. url=%s", s.imageURL)}
+	}
+
+	contentType := s.contentType
+	if contentType == "" {
+		contentType = utils.GetMimeTypeByFileExt(s.imageURL)
+	}
+
+	return img, contentType, nil
+}
+
+// fetchSourceImage fetches the image using the fetcher configured for path and decodes it
+func fetchSourceImage(c *fiber.Ctx, path string, imageURL string) (image.Image, string, error) {
+	source, err := fetchSource(c, path, imageURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return source.decode(c)
+}
+
+// applyManipulators executes the manipulator actions on the image in order
+func applyManipulators(c *fiber.Ctx, actions []*manipulatorAction, img image.Image) (image.Image, error) {
+	logger := middleware.GetLoggerFromContext(c)
+
+	// conditionalBlock tracks an if/else/endif block. active is true while the current branch is applied
+	type conditionalBlock struct {
+		parentActive bool
+		active       bool
+		matched      bool
 	}
+	var blocks []*conditionalBlock
+	isActive := func() bool {
+		return len(blocks) == 0 || blocks[len(blocks)-1].active
+	}
+
+	var err error
+	var dpr = 1.0
+	var hasDPR = false
+	for i, action := range actions {
+		if action == nil {
+			continue
+		}
+
+		switch action.Name {
+		case "if":
+			block := &conditionalBlock{parentActive: isActive()}
+			if block.parentActive {
+				if block.matched, err = evaluateCondition(action.Condition, img); err != nil {
+					return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("invalid if condition: %v", err)}
+				}
+			}
+			block.active = block.parentActive && block.matched
+			blocks = append(blocks, block)
+			continue
+		case "else":
+			if len(blocks) == 0 {
+				return nil, &requestError{fiber.StatusBadRequest, "else without a matching if"}
+			}
+			block := blocks[len(blocks)-1]
+			block.active = block.parentActive && !block.matched
+			block.matched = true
+			continue
+		case "endif":
+			if len(blocks) == 0 {
+				return nil, &requestError{fiber.StatusBadRequest, "endif without a matching if"}
+			}
+			blocks = blocks[:len(blocks)-1]
+			continue
+		}
+
+		if !isActive() {
+			logger.Debug().Str("manipulator", action.Name).Msg("Skipping manipulator in inactive branch")
+			continue
+		}
 
-	m := parseManipulators(c)
+		if action.Condition != "" {
+			matched, err := evaluateCondition(action.Condition, img)
+			if err != nil {
+				return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("invalid when condition of manipulator '%s': %v", action.Name, err)}
+			}
+			if !matched {
+				logger.Debug().Str("manipulator", action.Name).Msg("Skipping manipulator as its condition is not met")
+				continue
+			}
+		}
+
+		// The dpr step sets the device pixel ratio of the following manipulators
+		if action.Name == "dpr" {
+			if dpr, err = manipulators.ParseDPR(action.Params["v"]); err != nil {
+				return nil, &requestError{fiber.StatusBadRequest, err.Error()}
+			}
+			hasDPR = true
+			continue
+		}
 
-	for _, action := range m {
 		logger.Debug().Str("manipulator", action.Name).Msg("Applying manipulator")
 		manipulator := manipulators.GetManipulatorByName(action.Name)
 		if manipulator != nil {
+			params := action.Params
+			if hasDPR {
+				if params, dpr, err = manipulators.ScaleParamsForDPR(action.Name, params, dpr, img, config.GetConfig().GetMaxImageDimension()); err != nil {
+					return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("manipulator '%s': %v", action.Name, err)}
+				}
+			}
+
+			// Results cached by manipulators, such as detected faces, are only valid for the image they receive,
+			// which depends on the source image and on every step that ran before them
+			if key, ok := c.Locals(sourceKey).(string); ok {
+				c.Locals(manipulators.CacheScopeKey, key+"/"+canonicalChain(actions[:i]))
+			}
+
 			logger.Debug().Str("manipulator", action.Name).Msg("Executing manipulator")
-			if img, err = manipulator.Execute(c, action.Params, img); err != nil {
-				return c.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("failed to execute manipulator '%s'. Reason: %v", action.Name, err))
+			if img, err = manipulator.Execute(c, params, img); err != nil {
+				return nil, &requestError{fiber.StatusInternalServerError, fmt.Sprintf("failed to execute manipulator '%s'. Reason: %v", action.Name, err)}
 			}
 		}
 	}
 
-	outputContentType := c.GetRespHeader("Content-Type")
-	if outputContentType == "" {
-		outputContentType = contentType
-		c.Set("Content-Type", outputContentType)
+	if len(blocks) > 0 {
+		return nil, &requestError{fiber.StatusBadRequest, "if without a matching endif"}
+	}
+
+	if hasDPR {
+		c.Set("Content-DPR", strconv.FormatFloat(math.Round(dpr*100)/100, 'f', -1, 64))
 	}
 
+	return img, nil
+}
+
+// resetOutputHeaders removes the output settings manipulators store on the response, so that
+// intermediate chains do not leak their output format into the final image
+func resetOutputHeaders(c *fiber.Ctx) {
+	for _, header := range []string{"Content-Type", "Content-DPR", "X-Quality", "X-Lossless", "X-Exact", "X-Encoder"} {
+		c.Response().Header.Del(header)
+	}
+}
+
+// setCacheControl sets the Cache-Control header configured for the path, falling back to the global one
+func setCacheControl(c *fiber.Ctx, pathConfig *config.PathConfig) {
+	logger := middleware.GetLoggerFromContext(c)
+
 	var cacheControlHeaderValue = config.GetConfig().CacheControlHeader
 	if pathConfig != nil && pathConfig.CacheControl != "" {
 		cacheControlHeaderValue = pathConfig.CacheControl
@@ -443,8 +491,40 @@ func HandleImage(c *fiber.Ctx) error {
 		logger.Debug().Str("cacheControl", cacheControlHeaderValue).Msg("Applied cache control header")
 		c.Set("Cache-Control", cacheControlHeaderValue)
 	}
+}
+
+// outputContentType returns the output format set by an output manipulator, falling back to contentType
+func outputContentType(c *fiber.Ctx, contentType string) string {
+	// Without an output step the response still holds the default text content type
+	if outputContentType := c.GetRespHeader("Content-Type"); strings.HasPrefix(outputContentType, "image/") {
+		return outputContentType
+	}
+	return contentType
+}
+
+// pathBackground returns the color transparent images are flattened onto for formats without alpha
+func pathBackground(c *fiber.Ctx, pathConfig *config.PathConfig) color.Color {
+	if pathConfig != nil && pathConfig.Background != "" {
+		bg, err := manipulators.ParseColor(pathConfig.Background)
+		if err == nil {
+			return bg
+		}
+
+		logger := middleware.GetLoggerFromContext(c)
+		logger.Warn().Str("path", pathConfig.Path).Err(err).Msg("Invalid path background color, using white")
+	}
+
+	return color.White
+}
+
+// sendImage writes the image to the response. contentType is used unless a manipulator set the output format
+func sendImage(c *fiber.Ctx, pathConfig *config.PathConfig, contentType string, img image.Image) error {
+	contentType = outputContentType(c, contentType)
+	c.Set("Content-Type", contentType)
+
+	setCacheControl(c, pathConfig)
 
-	err = writeImageToResponse(c, outputContentType, img)
+	err := writeImageToResponse(c, contentType, img, pathBackground(c, pathConfig))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to write response")
 	}
@@ -452,3 +532,63 @@ func HandleImage(c *fiber.Ctx) error {
 	c.Status(fiber.StatusOK)
 	return nil
 }
+
+// HandleImage is the image handler
+func HandleImage(c *fiber.Ctx) error {
+	logger := middleware.GetLoggerFromContext(c)
+	logger.Debug().Str("path", c.Path()).Msg("Handling image request")
+
+	var imageURL string
+	var err error
+	imageURL, err = url.QueryUnescape(c.Params("url"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid URL passed. Have you tried URL escaping it?")
+	}
+	logger.Debug().Str("imageURL", imageURL).Msg("Decoded image URL")
+
+	var parsedURL *url.URL
+	if parsedURL, err = url.Parse(imageURL); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Unable to parse passed URL")
+	}
+
+	logger.Debug().Str("routePath", c.Route().Path).Msg("Searching for fetcher")
+	logger.Debug().Str("parsedURL", parsedURL.String()).Msg("Parsed URL")
+
+	rawRequestPath := c.Route().Path
+	path := rawRequestPath[0:strings.Index(rawRequestPath, "/:url")]
+	pathConfig := config.GetConfig().GetPathConfigByPath(path)
+
+	actions, err := parseManipulators(c)
+	if err != nil {
+		return respondWithError(c, err)
+	}
+
+	if actions, err = resolvePresets(c, pathConfig, actions); err != nil {
+		return respondWithError(c, err)
+	}
+
+	source, err := fetchSource(c, path, imageURL)
+	if err != nil {
+		return respondWithError(c, err)
+	}
+
+	// Equivalent requests on the same source content share the same ETag, so clients revalidating a cached result
+	// skip the processing, while a changed source image invalidates it
+	c.Set("ETag", requestETag(requestCacheKey(path, imageURL, actions), source.body))
+	if c.Fresh() {
+		setCacheControl(c, pathConfig)
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	img, contentType, err := source.decode(c)
+	if err != nil {
+		return respondWithError(c, err)
+	}
+
+	c.Locals(sourceKey, sourceCacheKey(path, imageURL))
+	if img, err = applyManipulators(c, actions, img); err != nil {
+		return respondWithError(c, err)
+	}
+
+	return sendImage(c, pathConfig, contentType, img)
+}
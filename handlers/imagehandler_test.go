@@ -12,9 +12,11 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/erans/thumbla/cache"
 	"github.com/erans/thumbla/config"
 	"github.com/erans/thumbla/fetchers"
 	"github.com/erans/thumbla/manipulators"
+	"github.com/erans/thumbla/manipulators/face"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -190,6 +192,11 @@ func TestHandleImage_Manipulations(t *testing.T) {
 			url:            "/test/test.jpg/output:f=png",
 			expectedStatus: fiber.StatusOK,
 		},
+		{
+			name:           "no output step",
+			url:            "/test/test.png/resize:w=50",
+			expectedStatus: fiber.StatusOK,
+		},
 	}
 
 	for _, tt := range tests {
@@ -256,4 +263,296 @@ func TestHandleImage_InvalidRequests(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestHandleImage_FlattenTransparentJPEG(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	req := httptest.NewRequest("GET", "/test/test.png/circle:/output:f=jpg", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to perform request: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	img, err := jpeg.Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// The transparent corners should be composited onto white instead of black
+	r, g, b, _ := img.At(0, 0).RGBA()
+	if r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Errorf("Expected white corner, got r=%d g=%d b=%d", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestHandleImage_DPR(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedWidth  int
+		expectedDPR    string
+	}{
+		{"dpr step", "/test/test.png/dpr:v=2/resize:w=40/output:f=png", fiber.StatusOK, 80, "2"},
+		{"dpr query", "/test/test.png/resize:w=40/output:f=png?dpr=2", fiber.StatusOK, 80, "2"},
+		{"capped at source resolution", "/test/test.png/resize:w=40/output:f=png?dpr=3", fiber.StatusOK, 100, "2.5"},
+		{"no dpr", "/test/test.png/resize:w=40/output:f=png", fiber.StatusOK, 40, ""},
+		{"invalid dpr", "/test/test.png/resize:w=40?dpr=10", fiber.StatusBadRequest, 0, ""},
+		{"extend capped at max dimension", "/test/test.png/dpr:v=5/extend:w=5000/output:f=png", fiber.StatusOK, 10000, "2"},
+		{"scaled beyond parameter limits", "/test/test.png/dpr:v=5/border:w=5000", fiber.StatusBadRequest, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			if dpr := resp.Header.Get("Content-DPR"); dpr != tt.expectedDPR {
+				t.Errorf("Expected Content-DPR %q, got %q", tt.expectedDPR, dpr)
+			}
+
+			img, err := png.Decode(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if img.Bounds().Dx() != tt.expectedWidth {
+				t.Errorf("Expected width %d, got %d", tt.expectedWidth, img.Bounds().Dx())
+			}
+		})
+	}
+}
+
+func TestHandleImage_FlattenPathBackground(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	cfg := config.GetConfig()
+	cfg.Paths[0].Background = "ff0000"
+	defer func() { cfg.Paths[0].Background = "" }()
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	req := httptest.NewRequest("GET", "/test/test.png/opacity:v=0/output:f=jpg", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to perform request: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	img, err := jpeg.Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// The fully transparent image should be composited onto the path background
+	r, g, b, _ := img.At(50, 50).RGBA()
+	if r>>8 < 240 || g>>8 > 15 || b>>8 > 15 {
+		t.Errorf("Expected red background, got r=%d g=%d b=%d", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestHandleImage_Presets(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	cfg := config.GetConfig()
+	cfg.Presets = map[string]string{
+		"thumb":  "resize:w=40/output:f=png",
+		"nested": "preset:name=thumb",
+		"loop":   "preset:name=loop",
+	}
+	defer func() {
+		cfg.Presets = nil
+		cfg.Paths[0].PresetsOnly = false
+	}()
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	tests := []struct {
+		name           string
+		url            string
+		presetsOnly    bool
+		expectedStatus int
+		expectedWidth  int
+	}{
+		{"preset", "/test/test.png/preset:name=thumb", false, fiber.StatusOK, 40},
+		{"nested preset", "/test/test.png/preset:name=nested", false, fiber.StatusOK, 40},
+		{"mixed with steps", "/test/test.png/preset:name=thumb/resize:w=20", false, fiber.StatusOK, 20},
+		{"conditional preset skipped", "/test/test.png/resize:w=30/preset:name=thumb,when=w%3E50/output:f=png", false, fiber.StatusOK, 30},
+		{"unknown preset", "/test/test.png/preset:name=missing", false, fiber.StatusBadRequest, 0},
+		{"preset cycle", "/test/test.png/preset:name=loop", false, fiber.StatusBadRequest, 0},
+		{"presets only", "/test/test.png/preset:name=thumb", true, fiber.StatusOK, 40},
+		{"presets only with dpr", "/test/test.png/preset:name=thumb?dpr=2", true, fiber.StatusOK, 80},
+		{"presets only rejects steps", "/test/test.png/resize:w=20/output:f=png", true, fiber.StatusForbidden, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Paths[0].PresetsOnly = tt.presetsOnly
+
+			req := httptest.NewRequest("GET", tt.url, nil)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			img, err := png.Decode(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if img.Bounds().Dx() != tt.expectedWidth {
+				t.Errorf("Expected width %d, got %d", tt.expectedWidth, img.Bounds().Dx())
+			}
+		})
+	}
+}
+
+func TestHandleImage_SVGDimensions(t *testing.T) {
+	tempDir, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "huge.svg"), []byte(hugeSVG), 0644); err != nil {
+		t.Fatalf("Failed to write test SVG: %v", err)
+	}
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedWidth  int
+	}{
+		{"size of the document", "/test/huge.svg/output:f=png", fiber.StatusInternalServerError, 0},
+		{"size from the url", "/test/huge.svg%7C100,100/output:f=png", fiber.StatusOK, 100},
+		{"huge size from the url", "/test/huge.svg%7C50000,50000/output:f=png", fiber.StatusInternalServerError, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.url, nil))
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			img, err := png.Decode(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if img.Bounds().Dx() != tt.expectedWidth {
+				t.Errorf("Expected width %d, got %d", tt.expectedWidth, img.Bounds().Dx())
+			}
+		})
+	}
+}
+
+// countingDetector finds no faces and counts the images it was called on
+type countingDetector struct {
+	calls int
+}
+
+func (d *countingDetector) Detect(c *fiber.Ctx, cfg *config.Config, params map[string]string, img image.Image) ([]image.Rectangle, error) {
+	d.calls++
+	return nil, nil
+}
+
+func TestHandleImage_FacesCache(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	cfg := config.GetConfig()
+	cfg.Cache.Provider = cache.CacheInMemory
+	cache.InitCache(cfg)
+	defer func() {
+		cfg.Cache.Provider = cache.CacheDummy
+		cache.InitCache(cfg)
+	}()
+
+	detector := &countingDetector{}
+	face.RegisterDetector("counting", detector)
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	// Face boxes found on the source image must not be reused after a step that moved the faces
+	tests := []struct {
+		name          string
+		url           string
+		expectedCalls int
+	}{
+		{"source image", "/test/test.png/redact:provider=counting/output:f=png", 1},
+		{"cached source image", "/test/test.png/redact:provider=counting/output:f=png", 1},
+		{"flipped image", "/test/test.png/fliph/redact:provider=counting/output:f=png", 2},
+		{"cached flipped image", "/test/test.png/fliph/redact:provider=counting/output:f=png", 2},
+		{"rotated image", "/test/test.png/rotate:a=180/redact:provider=counting/output:f=png", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.url, nil))
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != fiber.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, string(body))
+			}
+
+			if detector.calls != tt.expectedCalls {
+				t.Errorf("Expected %d face detections, got %d", tt.expectedCalls, detector.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/manipulators"
+)
+
+// builtinStepSchemas describes the steps that are handled by the handlers themselves rather than by a manipulator
+var builtinStepSchemas = map[string]manipulators.Schema{
+	"dpr": {
+		Description: "Multiplies the pixel values of the following steps by a device pixel ratio",
+		Params: []manipulators.ParamSchema{
+			manipulators.Param("v", manipulators.ParamFloat, "device pixel ratio").Range(1, manipulators.MaxDPR).AsRequired(),
+		},
+	},
+	"preset": {
+		Description: "Runs a named chain from the presets section of the config",
+		Params: []manipulators.ParamSchema{
+			manipulators.Param("name", manipulators.ParamString, "preset name").AsRequired(),
+		},
+	},
+	"if": {
+		Description: "Runs the following steps up to else or endif only when the condition given instead of parameters (e.g. if:w>2000) is true",
+	},
+	"else": {
+		Description: "Runs the following steps up to endif only when the condition of the matching if is false",
+	},
+	"endif": {
+		Description: "Ends an if block",
+	},
+}
+
+// manipulatorDescription is a single entry of the /manipulators response
+type manipulatorDescription struct {
+	Name string `json:"name"`
+	manipulators.Schema
+}
+
+// getStepSchema returns the schema of a built-in step or of a registered manipulator
+func getStepSchema(name string) (manipulators.Schema, bool) {
+	if schema, ok := builtinStepSchemas[name]; ok {
+		return schema, true
+	}
+
+	if manipulator := manipulators.GetManipulatorByName(name); manipulator != nil {
+		return manipulator.Schema(), true
+	}
+
+	return manipulators.Schema{}, false
+}
+
+// HandleManipulators describes every step that can be used in a manipulators chain and its parameters
+func HandleManipulators(c *fiber.Ctx) error {
+	var result []manipulatorDescription
+	for name, schema := range manipulators.GetSchemas() {
+		result = append(result, manipulatorDescription{Name: name, Schema: schema})
+	}
+	for name, schema := range builtinStepSchemas {
+		result = append(result, manipulatorDescription{Name: name, Schema: schema})
+	}
+
+	for i := range result {
+		// Always return a list, so clients don't have to handle steps without parameters specially
+		if result[i].Params == nil {
+			result[i].Params = []manipulators.ParamSchema{}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return c.JSON(result)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"image/png"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/manipulators"
+)
+
+func TestHandleManipulators(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Get("/manipulators", HandleManipulators)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/manipulators", nil))
+	if err != nil {
+		t.Fatalf("Failed to perform request: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	var result []manipulatorDescription
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	byName := map[string]manipulatorDescription{}
+	for _, m := range result {
+		byName[m.Name] = m
+	}
+
+	for _, name := range []string{"resize", "crop", "output", "circle", "dpr", "preset", "if", "else", "endif"} {
+		if _, ok := byName[name]; !ok {
+			t.Errorf("Expected %s to be described", name)
+		}
+	}
+
+	var filter *manipulators.ParamSchema
+	for _, p := range byName["resize"].Params {
+		if p.Name == "r" {
+			filter = &p
+		}
+	}
+	if filter == nil || filter.Type != manipulators.ParamEnum || filter.Default != "linear" {
+		t.Fatalf("Expected resize r to be an enum defaulting to linear, got %+v", filter)
+	}
+}
+
+func TestHandleImage_ParameterSchemas(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Get("/test/:url/*", HandleImage)
+
+	tests := []struct {
+		name          string
+		url           string
+		expectedWidth int
+	}{
+		{"resize filter", "/test/test.png/resize:w=40,r=lanczos/output:f=png", 40},
+		{"crop rectangle", "/test/test.png/crop:r=0|0|30|30/output:f=png", 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.url, nil))
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+			}
+
+			img, err := png.Decode(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if img.Bounds().Dx() != tt.expectedWidth {
+				t.Errorf("Expected width %d, got %d", tt.expectedWidth, img.Bounds().Dx())
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/config"
+	"github.com/erans/thumbla/middleware"
+)
+
+// pipelineSource references the source image through a configured path
+type pipelineSource struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+}
+
+// pipelineStep is a single manipulator step. Parameter values may be strings, numbers, booleans or lists of
+// those, which are joined with '|'. Condition is the condition of an "if" step, When makes any other step
+// conditional
+type pipelineStep struct {
+	Name      string                 `json:"name"`
+	Params    map[string]interface{} `json:"params"`
+	When      string                 `json:"when"`
+	Condition string                 `json:"condition"`
+}
+
+// pipelineRequest is a JSON pipeline definition. Output holds the parameters of a final output step
+type pipelineRequest struct {
+	Source pipelineSource         `json:"source"`
+	Steps  []pipelineStep         `json:"steps"`
+	Output map[string]interface{} `json:"output"`
+}
+
+// pipelineParamValue converts a typed JSON parameter value to its string form in a manipulators chain
+func pipelineParamValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			if _, isList := item.([]interface{}); isList {
+				return "", fmt.Errorf("nested lists are not supported")
+			}
+			part, err := pipelineParamValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = part
+		}
+		return strings.Join(parts, "|"), nil
+	case nil:
+		return "", nil
+	}
+
+	return "", fmt.Errorf("unsupported value type %T", value)
+}
+
+// pipelineParams converts the typed JSON parameters of a step
+func pipelineParams(params map[string]interface{}) (map[string]string, error) {
+	result := make(map[string]string, len(params))
+	for key, value := range params {
+		v, err := pipelineParamValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %v", key, err)
+		}
+		result[key] = v
+	}
+	return result, nil
+}
+
+// actions validates the steps of the pipeline and returns them as manipulator actions, followed by the output step
+func (p *pipelineRequest) actions() ([]*manipulatorAction, error) {
+	steps := p.Steps
+	if p.Output != nil {
+		steps = append(steps[:len(steps):len(steps)], pipelineStep{Name: "output", Params: p.Output})
+	}
+
+	actions := make([]*manipulatorAction, 0, len(steps))
+	for i, step := range steps {
+		action, err := step.action()
+		if err != nil {
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("invalid step %d '%s': %v", i+1, step.Name, err)}
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+// action validates the step and returns it as a manipulator action
+func (s *pipelineStep) action() (*manipulatorAction, error) {
+	var condition = s.When
+	if s.Name == "if" {
+		condition = s.Condition
+	} else if s.Condition != "" {
+		return nil, fmt.Errorf("condition is only supported by if steps, use when")
+	}
+
+	params, err := pipelineParams(s.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStepAction(s.Name, params, condition)
+}
+
+// findPathConfig returns the configured path matching path, ignoring a trailing '/'
+func findPathConfig(path string) *config.PathConfig {
+	cfg := config.GetConfig()
+	for i, p := range cfg.Paths {
+		if strings.TrimSuffix(p.Path, "/") == strings.TrimSuffix(path, "/") {
+			return &cfg.Paths[i]
+		}
+	}
+	return nil
+}
+
+// HandlePipeline renders an image described by a JSON pipeline definition in the request body, for chains that
+// are too long or complex to express in a URL:
+//
+//	{
+//	  "source": {"path": "/i/pics/", "url": "path/to/image.jpg"},
+//	  "steps": [
+//	    {"name": "resize", "params": {"w": 350, "r": "lanczos"}},
+//	    {"name": "rotate", "params": {"a": 90}, "when": "portrait"}
+//	  ],
+//	  "output": {"f": "jpg", "q": 80}
+//	}
+func HandlePipeline(c *fiber.Ctx) error {
+	logger := middleware.GetLoggerFromContext(c)
+	logger.Debug().Msg("Handling pipeline request")
+
+	var req pipelineRequest
+	decoder := json.NewDecoder(bytes.NewReader(c.Body()))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Invalid pipeline definition: %v", err))
+	}
+
+	pathConfig := findPathConfig(req.Source.Path)
+	if pathConfig == nil {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("No path is configured for source path '%s'", req.Source.Path))
+	}
+
+	if req.Source.URL == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("A source url is required")
+	}
+
+	actions, err := req.actions()
+	if err != nil {
+		return respondWithError(c, err)
+	}
+
+	if actions, err = resolvePresets(c, pathConfig, actions); err != nil {
+		return respondWithError(c, err)
+	}
+
+	img, contentType, err := fetchSourceImage(c, pathConfig.Path, req.Source.URL)
+	if err != nil {
+		return respondWithError(c, err)
+	}
+
+	if img, err = applyManipulators(c, actions, img); err != nil {
+		return respondWithError(c, err)
+	}
+
+	return sendImage(c, pathConfig, contentType, img)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"image/png"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/config"
+)
+
+func TestHandlePipeline(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Post("/pipeline", HandlePipeline)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedWidth  int
+		expectedError  string
+	}{
+		{
+			name:           "typed parameters",
+			body:           `{"source": {"path": "/test", "url": "test.jpg"}, "steps": [{"name": "resize", "params": {"w": 40, "r": "lanczos", "upscale": false}}], "output": {"f": "png"}}`,
+			expectedStatus: fiber.StatusOK,
+			expectedWidth:  40,
+		},
+		{
+			name:           "list parameter",
+			body:           `{"source": {"path": "/test/", "url": "test.png"}, "steps": [{"name": "crop", "params": {"r": [0, 0, 30, 20]}}], "output": {"f": "png"}}`,
+			expectedStatus: fiber.StatusOK,
+			expectedWidth:  30,
+		},
+		{
+			name:           "conditional steps",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "steps": [{"name": "if", "condition": "w>50"}, {"name": "resize", "params": {"w": 50}}, {"name": "endif"}, {"name": "resize", "params": {"w": 20}, "when": "w>60"}], "output": {"f": "png"}}`,
+			expectedStatus: fiber.StatusOK,
+			expectedWidth:  50,
+		},
+		{
+			name:           "invalid json",
+			body:           `{"source": `,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "Invalid pipeline definition",
+		},
+		{
+			name:           "unknown field",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "stages": []}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "unknown field",
+		},
+		{
+			name:           "unknown path",
+			body:           `{"source": {"path": "/other", "url": "test.png"}}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "No path is configured",
+		},
+		{
+			name:           "invalid step",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "steps": [{"name": "resize", "params": {"w": 40}}, {"name": "rotate", "params": {"a": "abc"}}]}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "invalid step 2 'rotate'",
+		},
+		{
+			name:           "invalid output",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "output": {"f": "bmp"}}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "invalid step 1 'output'",
+		},
+		{
+			name:           "unsupported value",
+			body:           `{"source": {"path": "/test", "url": "test.png"}, "steps": [{"name": "resize", "params": {"w": {"px": 40}}}]}`,
+			expectedStatus: fiber.StatusBadRequest,
+			expectedError:  "parameter w: unsupported value type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/pipeline", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if tt.expectedStatus != fiber.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				if !strings.Contains(string(body), tt.expectedError) {
+					t.Errorf("Expected error to contain %q, got %q", tt.expectedError, string(body))
+				}
+				return
+			}
+
+			img, err := png.Decode(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if img.Bounds().Dx() != tt.expectedWidth {
+				t.Errorf("Expected width %d, got %d", tt.expectedWidth, img.Bounds().Dx())
+			}
+		})
+	}
+}
+
+func TestHandlePipeline_PresetsOnly(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	cfg := config.GetConfig()
+	cfg.Presets = map[string]string{"thumb": "resize:w=40/output:f=png"}
+	cfg.Paths[0].PresetsOnly = true
+	defer func() {
+		cfg.Presets = nil
+		cfg.Paths[0].PresetsOnly = false
+	}()
+
+	app := fiber.New()
+	app.Post("/pipeline", HandlePipeline)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"preset", `{"source": {"path": "/test", "url": "test.png"}, "steps": [{"name": "preset", "params": {"name": "thumb"}}]}`, fiber.StatusOK},
+		{"arbitrary step", `{"source": {"path": "/test", "url": "test.png"}, "steps": [{"name": "resize", "params": {"w": 40}}]}`, fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("POST", "/pipeline", strings.NewReader(tt.body)))
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+}
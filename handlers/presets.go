@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/config"
+)
+
+// Presets may reference other presets up to this depth, which also stops reference cycles
+const maxPresetDepth = 5
+
+// expandPresets replaces every preset step with the manipulators chain of the named preset from the config.
+// A "when" condition on a preset step applies to its whole chain
+func expandPresets(c *fiber.Ctx, actions []*manipulatorAction, depth int) ([]*manipulatorAction, error) {
+	var result []*manipulatorAction
+	for _, action := range actions {
+		if action == nil || action.Name != "preset" {
+			result = append(result, action)
+			continue
+		}
+
+		if depth >= maxPresetDepth {
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("presets are nested more than %d levels deep", maxPresetDepth)}
+		}
+
+		name := action.Params["name"]
+		chain, ok := config.GetConfig().Presets[name]
+		if !ok {
+			return nil, &requestError{fiber.StatusBadRequest, fmt.Sprintf("unknown preset '%s'", name)}
+		}
+
+		parsed, err := parseManipulatorsString(c, chain)
+		if err != nil {
+			return nil, &requestError{fiber.StatusInternalServerError, fmt.Sprintf("invalid chain of preset '%s': %v", name, err)}
+		}
+
+		expanded, err := expandPresets(c, parsed, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		if action.Condition != "" {
+			result = append(result, &manipulatorAction{Name: "if", Params: map[string]string{}, Condition: action.Condition})
+			result = append(result, expanded...)
+			result = append(result, &manipulatorAction{Name: "endif", Params: map[string]string{}})
+		} else {
+			result = append(result, expanded...)
+		}
+	}
+
+	return result, nil
+}
+
+// resolvePresets checks that the chain is allowed on the path and expands its presets. Chains are resolved once,
+// before the source is fetched, so the actions passed to applyManipulators never contain presets
+func resolvePresets(c *fiber.Ctx, pathConfig *config.PathConfig, actions []*manipulatorAction) ([]*manipulatorAction, error) {
+	if err := checkPresetsOnly(pathConfig, actions); err != nil {
+		return nil, err
+	}
+
+	return expandPresets(c, actions, 0)
+}
+
+// checkPresetsOnly rejects chains with steps other than presets on paths configured with presetsOnly
+func checkPresetsOnly(pathConfig *config.PathConfig, actions []*manipulatorAction) error {
+	if pathConfig == nil || !pathConfig.PresetsOnly {
+		return nil
+	}
+
+	for _, action := range actions {
+		// The dpr step is allowed as it is bounded and only picks the density of a preset
+		if action != nil && action.Name != "preset" && action.Name != "dpr" {
+			return &requestError{fiber.StatusForbidden, fmt.Sprintf("only presets are allowed on this path, got '%s'", action.Name)}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/config"
+	"github.com/erans/thumbla/middleware"
+)
+
+// uploadContentTypes lists the image types accepted by uploads. Vector images are rasterized at a size of their
+// own choosing, so only raster images are accepted
+var uploadContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/jpg":  true,
+	"image/png":  true,
+	"image/webp": true,
+	"image/gif":  true,
+}
+
+// uploadContentType returns the media type of an uploaded image, sniffing it from the body when the declared
+// type is missing or generic
+func uploadContentType(declared string, body []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(declared); err == nil && strings.HasPrefix(mediaType, "image/") {
+		return mediaType
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	return mediaType
+}
+
+// readUpload returns the uploaded image body, its declared content type and the manipulators chain given in the
+// form fields. The image is either the raw request body or the "image" file of a multipart form
+func readUpload(c *fiber.Ctx) ([]byte, string, string, error) {
+	maxSize := config.GetConfig().GetMaxImageSizeBytes()
+
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		body := c.Body()
+		if int64(len(body)) > maxSize {
+			return nil, "", "", &requestError{fiber.StatusRequestEntityTooLarge, fmt.Sprintf("image size (%d bytes) exceeds maximum allowed size (%d bytes)", len(body), maxSize)}
+		}
+		return body, c.Get(fiber.HeaderContentType), "", nil
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, "", "", &requestError{fiber.StatusBadRequest, fmt.Sprintf("invalid multipart form: %v", err)}
+	}
+
+	files := form.File["image"]
+	if len(files) == 0 {
+		return nil, "", "", &requestError{fiber.StatusBadRequest, "multipart uploads require an image file field"}
+	}
+
+	if files[0].Size > maxSize {
+		return nil, "", "", &requestError{fiber.StatusRequestEntityTooLarge, fmt.Sprintf("image size (%d bytes) exceeds maximum allowed size (%d bytes)", files[0].Size, maxSize)}
+	}
+
+	file, err := files[0].Open()
+	if err != nil {
+		return nil, "", "", &requestError{fiber.StatusBadRequest, fmt.Sprintf("failed to read uploaded image: %v", err)}
+	}
+	defer file.Close()
+
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", "", &requestError{fiber.StatusBadRequest, fmt.Sprintf("failed to read uploaded image: %v", err)}
+	}
+
+	var chain string
+	if values := form.Value["chain"]; len(values) > 0 {
+		chain = values[0]
+	}
+
+	return body, files[0].Header.Get(fiber.HeaderContentType), chain, nil
+}
+
+// HandleUpload transforms an image uploaded in the request body, either raw or as the "image" file of a
+// multipart form, with the manipulators chain given in the URL (/upload/<manipulators>) or in the "chain"
+// form field
+func HandleUpload(c *fiber.Ctx) error {
+	logger := middleware.GetLoggerFromContext(c)
+	logger.Debug().Str("path", c.Path()).Msg("Handling upload request")
+
+	body, declaredContentType, formChain, err := readUpload(c)
+	if err != nil {
+		return respondWithError(c, err)
+	}
+
+	if len(body) == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("An image body is required")
+	}
+
+	actions, err := parseManipulators(c)
+	if err != nil {
+		return respondWithError(c, err)
+	}
+
+	if formChain != "" {
+		if c.Params("*") != "" {
+			return c.Status(fiber.StatusBadRequest).SendString("The manipulators chain can be given either in the URL or in the chain form field")
+		}
+
+		formActions, err := parseManipulatorsString(c, formChain)
+		if err != nil {
+			return respondWithError(c, err)
+		}
+		actions = append(actions, formActions...)
+	}
+
+	if actions, err = resolvePresets(c, nil, actions); err != nil {
+		return respondWithError(c, err)
+	}
+
+	contentType := uploadContentType(declaredContentType, body)
+	if !uploadContentTypes[contentType] {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Unsupported image type '%s'. Uploads support jpeg, png, webp and gif images", contentType))
+	}
+
+	img, err := loadImage(c, "", contentType, bytes.NewReader(body), -1, -1)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Failed to load uploaded image: %v", err))
+	}
+
+	if img, err = applyManipulators(c, actions, img); err != nil {
+		return respondWithError(c, err)
+	}
+
+	return sendImage(c, nil, contentType, img)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bytes"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/erans/thumbla/config"
+)
+
+// newMultipartUpload builds a multipart form with an image file and an optional chain field
+// hugeSVG is a tiny document that would be rasterized into a 30000x30000 image
+const hugeSVG = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 30000 30000"><rect width="10" height="10"/></svg>`
+
+func newMultipartUpload(t *testing.T, image []byte, contentType string, chain string) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="image"; filename="upload"`)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(image)
+
+	if chain != "" {
+		writer.WriteField("chain", chain)
+	}
+	writer.Close()
+
+	return &buf, writer.FormDataContentType()
+}
+
+func TestHandleUpload(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	pngData, err := createTestImage(100, 100, "png")
+	if err != nil {
+		t.Fatalf("Failed to create test PNG: %v", err)
+	}
+
+	app := fiber.New()
+	app.Post("/upload/*", HandleUpload)
+	app.Put("/upload/*", HandleUpload)
+
+	multipartBody, multipartType := newMultipartUpload(t, pngData, "", "resize:w=30/output:f=png")
+	multipartBothBody, multipartBothType := newMultipartUpload(t, pngData, "image/png", "resize:w=30")
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           []byte
+		contentType    string
+		expectedStatus int
+		expectedType   string
+		expectedWidth  int
+	}{
+		{"raw body", "POST", "/upload/resize:w=40/output:f=png", pngData, "image/png", fiber.StatusOK, "image/png", 40},
+		{"raw body with put", "PUT", "/upload/resize:w=40/output:f=jpg", pngData, "image/png", fiber.StatusOK, "image/jpeg", 40},
+		{"sniffed content type", "POST", "/upload/resize:w=40", pngData, "application/octet-stream", fiber.StatusOK, "image/png", 40},
+		{"no chain", "POST", "/upload/", pngData, "", fiber.StatusOK, "image/png", 100},
+		{"multipart with chain field", "POST", "/upload/", multipartBody.Bytes(), multipartType, fiber.StatusOK, "image/png", 30},
+		{"chain in url and form", "POST", "/upload/flipv", multipartBothBody.Bytes(), multipartBothType, fiber.StatusBadRequest, "", 0},
+		{"empty body", "POST", "/upload/resize:w=40", nil, "image/png", fiber.StatusBadRequest, "", 0},
+		{"not an image", "POST", "/upload/resize:w=40", []byte("hello"), "text/plain", fiber.StatusBadRequest, "", 0},
+		{"invalid chain", "POST", "/upload/resize:w=abc", pngData, "image/png", fiber.StatusBadRequest, "", 0},
+		{"svg", "POST", "/upload/output:f=png", []byte(hugeSVG), "image/svg+xml", fiber.StatusBadRequest, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				b, _ := io.ReadAll(resp.Body)
+				t.Fatalf("Expected status %d, got %d %s", tt.expectedStatus, resp.StatusCode, b)
+			}
+
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			if contentType := resp.Header.Get("Content-Type"); contentType != tt.expectedType {
+				t.Fatalf("Expected content type %s, got %s", tt.expectedType, contentType)
+			}
+
+			decode := png.Decode
+			if tt.expectedType == "image/jpeg" {
+				decode = jpeg.Decode
+			}
+			img, err := decode(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if img.Bounds().Dx() != tt.expectedWidth {
+				t.Errorf("Expected width %d, got %d", tt.expectedWidth, img.Bounds().Dx())
+			}
+		})
+	}
+}
+
+func TestHandleUpload_MaxImageSize(t *testing.T) {
+	_, cleanup := setupTestEnvironment(t)
+	defer cleanup()
+
+	cfg := config.GetConfig()
+	cfg.Server.MaxImageSizeBytes = 100
+	defer func() { cfg.Server.MaxImageSizeBytes = 0 }()
+
+	pngData, err := createTestImage(100, 100, "png")
+	if err != nil {
+		t.Fatalf("Failed to create test PNG: %v", err)
+	}
+
+	app := fiber.New()
+	app.Post("/upload/*", HandleUpload)
+
+	multipartBody, multipartType := newMultipartUpload(t, pngData, "image/png", "")
+
+	for name, req := range map[string]struct {
+		body        []byte
+		contentType string
+	}{
+		"raw":       {pngData, "image/png"},
+		"multipart": {multipartBody.Bytes(), multipartType},
+	} {
+		t.Run(name, func(t *testing.T) {
+			httpReq := httptest.NewRequest("POST", "/upload/", bytes.NewReader(req.body))
+			httpReq.Header.Set("Content-Type", req.contentType)
+			resp, err := app.Test(httpReq)
+			if err != nil {
+				t.Fatalf("Failed to perform request: %v", err)
+			}
+
+			if resp.StatusCode != fiber.StatusRequestEntityTooLarge {
+				t.Errorf("Expected status %d, got %d", fiber.StatusRequestEntityTooLarge, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AffineManipulator applies an arbitrary 2x3 affine transformation matrix to the image
+//
+// Supported parameters:
+// - m (string) - matrix values a|b|c|d|e|f mapping every point to x' = a*x + b*y + c, y' = d*x + e*y + f
+// - fit (boolean - 0/1) - resize the canvas to contain the complete transformed image (default 1). When 0 the original size is kept
+// - interp (string) - nearest, bilinear (default) or bicubic interpolation
+// - bg (color) - color of the areas not covered by the image (default transparent)
+type AffineManipulator struct {
+	Cfg *config.Config
+}
+
+// Execute runs the affine manipulator
+func (manipulator *AffineManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	v, ok := params["m"]
+	if !ok {
+		return nil, fmt.Errorf("affine requires a matrix (m) parameter")
+	}
+
+	m, err := parseFloats("matrix", v, 6)
+	if err != nil {
+		return nil, err
+	}
+
+	det := m[0]*m[4] - m[1]*m[3]
+	if math.Abs(det) < 1e-9 {
+		return nil, fmt.Errorf("affine matrix is not invertible")
+	}
+
+	interpolation, err := parseInterpolation(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var bg = namedColors["transparent"]
+	if v, ok := params["bg"]; ok {
+		if bg, err = parseColor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	src := toNRGBA(img)
+	w := float64(src.Bounds().Dx())
+	h := float64(src.Bounds().Dy())
+
+	// Translate the transformed image so that its bounding box starts at the origin
+	var tx, ty float64
+	size := src.Bounds().Size()
+	if params["fit"] != "0" {
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, p := range [][2]float64{{0, 0}, {w, 0}, {0, h}, {w, h}} {
+			x := m[0]*p[0] + m[1]*p[1] + m[2]
+			y := m[3]*p[0] + m[4]*p[1] + m[5]
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+
+		tx, ty = -minX, -minY
+		size = image.Pt(int(math.Ceil(maxX-minX-1e-6)), int(math.Ceil(maxY-minY-1e-6)))
+	}
+
+	if err := checkCanvasSize(manipulator.Cfg, size); err != nil {
+		return nil, err
+	}
+
+	inverse := func(x, y float64) (float64, float64) {
+		x -= m[2] + tx
+		y -= m[5] + ty
+		return (m[4]*x - m[1]*y) / det, (m[0]*y - m[3]*x) / det
+	}
+
+	return warpImage(src, size, inverse, interpolation, bg), nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *AffineManipulator) Schema() Schema {
+	return Schema{
+		Description: "Applies a 2x3 affine transformation matrix",
+		Params: []ParamSchema{
+			Param("m", ParamString, "matrix values in the form of a|b|c|d|e|f").AsRequired(),
+			Param("fit", ParamBool, "resize the canvas to contain the transformed image").WithDefault("1"),
+			interpolationParam(),
+			Param("bg", ParamColor, "color of the areas not covered by the image").WithDefault("transparent"),
+		},
+	}
+}
+
+// NewAffineManipulator returns a new affine Manipulator
+func NewAffineManipulator(cfg *config.Config) *AffineManipulator {
+	return &AffineManipulator{Cfg: cfg}
+}
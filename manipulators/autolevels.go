@@ -0,0 +1,74 @@
+package manipulators
+
+import (
+	"image"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AutoLevelsManipulator stretches the tonal range of the image so that its darkest and brightest tones
+// become black and white
+//
+// Supported parameters:
+// - s (float 0-100) - strength in percentages with which the correction is applied (default 100)
+// - clip (float 0-100) - percentage of the darkest and brightest pixels ignored when looking for the tonal range (default 0.5)
+type AutoLevelsManipulator struct {
+}
+
+// Execute runs the auto levels manipulator
+func (manipulator *AutoLevelsManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	strength, err := parsePercentageParam(params, "s", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	clip, err := parsePercentageParam(params, "clip", 0.5)
+	if err != nil {
+		return nil, err
+	}
+
+	result := toNRGBA(img)
+	hist, total := luminanceHistogram(result, result.Bounds())
+	if total == 0 {
+		return result, nil
+	}
+
+	low := float64(histogramPercentile(hist, total, clip))
+	high := float64(histogramPercentile(hist, total, 1-clip))
+	if high <= low {
+		// A flat image has no tonal range to stretch
+		return result, nil
+	}
+
+	scale := 255 / (high - low)
+	b := result.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := result.PixOffset(x, y)
+			px := result.Pix[i : i+3 : i+3]
+			for ch := range px {
+				v := float64(px[ch])
+				px[ch] = clampChannel(v + ((v-low)*scale-v)*strength)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *AutoLevelsManipulator) Schema() Schema {
+	return Schema{
+		Description: "Stretches the tonal range of the image",
+		Params: []ParamSchema{
+			Param("s", ParamFloat, "strength in percentages").Range(0, 100).WithDefault("100"),
+			Param("clip", ParamFloat, "percentage of the darkest and brightest pixels ignored").Range(0, 100).WithDefault("0.5"),
+		},
+	}
+}
+
+// NewAutoLevelsManipulator returns a new auto levels Manipulator
+func NewAutoLevelsManipulator(cfg *config.Config) *AutoLevelsManipulator {
+	return &AutoLevelsManipulator{}
+}
@@ -0,0 +1,126 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	autoWBModeGrayWorld  = "grayworld"
+	autoWBModeWhitePatch = "whitepatch"
+
+	// Limit the per channel gain to avoid blowing up images dominated by a single color
+	autoWBMaxGain = 4.0
+)
+
+// AutoWhiteBalanceManipulator removes color casts by scaling the color channels
+//
+// Supported parameters:
+// - mode (string) - grayworld (default) assumes the average color is neutral gray, whitepatch assumes the brightest tones are white
+// - s (float 0-100) - strength in percentages with which the correction is applied (default 100)
+// - clip (float 0-100) - percentage of the brightest pixels ignored by whitepatch to skip specular highlights (default 1)
+type AutoWhiteBalanceManipulator struct {
+}
+
+// Execute runs the auto white balance manipulator
+func (manipulator *AutoWhiteBalanceManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var mode = autoWBModeGrayWorld
+	if v, ok := params["mode"]; ok {
+		if v != autoWBModeGrayWorld && v != autoWBModeWhitePatch {
+			return nil, fmt.Errorf("unknown white balance mode '%s'", v)
+		}
+		mode = v
+	}
+
+	strength, err := parsePercentageParam(params, "s", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	clip, err := parsePercentageParam(params, "clip", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	result := toNRGBA(img)
+	b := result.Bounds()
+
+	var hists [3][256]int
+	var sums [3]float64
+	var total int
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := result.PixOffset(x, y)
+			px := result.Pix[i : i+4 : i+4]
+			if px[3] == 0 {
+				continue
+			}
+			for ch := 0; ch < 3; ch++ {
+				hists[ch][px[ch]]++
+				sums[ch] += float64(px[ch])
+			}
+			total++
+		}
+	}
+
+	if total == 0 {
+		return result, nil
+	}
+
+	var references [3]float64
+	var target float64
+	for ch := 0; ch < 3; ch++ {
+		if mode == autoWBModeWhitePatch {
+			references[ch] = float64(histogramPercentile(hists[ch], total, 1-clip))
+		} else {
+			references[ch] = sums[ch] / float64(total)
+		}
+		target += references[ch] / 3
+	}
+
+	if mode == autoWBModeWhitePatch {
+		target = 255
+	}
+
+	var gains [3]float64
+	for ch := 0; ch < 3; ch++ {
+		gains[ch] = 1
+		if references[ch] > 0 {
+			gains[ch] = math.Min(autoWBMaxGain, target/references[ch])
+		}
+		gains[ch] = 1 + (gains[ch]-1)*strength
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := result.PixOffset(x, y)
+			px := result.Pix[i : i+3 : i+3]
+			for ch := range px {
+				px[ch] = clampChannel(float64(px[ch]) * gains[ch])
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *AutoWhiteBalanceManipulator) Schema() Schema {
+	return Schema{
+		Description: "Removes color casts",
+		Params: []ParamSchema{
+			EnumParam("mode", "white balance algorithm", "grayworld", "whitepatch").WithDefault("grayworld"),
+			Param("s", ParamFloat, "strength in percentages").Range(0, 100).WithDefault("100"),
+			Param("clip", ParamFloat, "percentage of the brightest pixels ignored by whitepatch").Range(0, 100).WithDefault("1"),
+		},
+	}
+}
+
+// NewAutoWhiteBalanceManipulator returns a new auto white balance Manipulator
+func NewAutoWhiteBalanceManipulator(cfg *config.Config) *AutoWhiteBalanceManipulator {
+	return &AutoWhiteBalanceManipulator{}
+}
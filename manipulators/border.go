@@ -0,0 +1,116 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// BorderManipulator adds a solid border around the image, extending the canvas
+//
+// Supported parameters:
+// - w (int) - border width in pixels
+// - c (color) - border color as a hex value (RGB, RRGGBB or RRGGBBAA) or a color name (default black)
+// - r (float) - optional radius in pixels of the outer corners. The image corners are rounded to match
+type BorderManipulator struct {
+	Cfg *config.Config
+}
+
+// Execute runs the border manipulator and adds a border around the image
+func (manipulator *BorderManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	v, ok := params["w"]
+	if !ok {
+		return img, nil
+	}
+
+	width, err := strconv.Atoi(v)
+	if err != nil || width < 1 {
+		return nil, fmt.Errorf("invalid border width (w) value")
+	}
+
+	var borderColor = color.NRGBA{0, 0, 0, 255}
+	if v, ok := params["c"]; ok {
+		if borderColor, err = parseColor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var radius = 0.0
+	if v, ok := params["r"]; ok {
+		if radius, err = strconv.ParseFloat(v, 64); err != nil || radius < 0 {
+			return nil, fmt.Errorf("invalid border radius (r) value")
+		}
+	}
+
+	src := toNRGBA(img)
+	srcW := src.Bounds().Dx()
+	srcH := src.Bounds().Dy()
+	w := srcW + 2*width
+	h := srcH + 2*width
+	if err := checkCanvasSize(manipulator.Cfg, image.Pt(w, h)); err != nil {
+		return nil, err
+	}
+
+	outerRadii := cornerRadii{radius, radius, radius, radius}.clamp(float64(w), float64(h))
+	innerRadius := math.Max(0, radius-float64(width))
+	innerRadii := cornerRadii{innerRadius, innerRadius, innerRadius, innerRadius}.clamp(float64(srcW), float64(srcH))
+
+	br := float64(borderColor.R)
+	bg := float64(borderColor.G)
+	bb := float64(borderColor.B)
+	ba := float64(borderColor.A) / 255
+
+	result := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			px := float64(x) + 0.5
+			py := float64(y) + 0.5
+
+			var sr, sg, sb, sa float64
+			if x >= width && x < width+srcW && y >= width && y < width+srcH {
+				i := src.PixOffset(x-width, y-width)
+				coverage := roundedRectCoverage(px-float64(width), py-float64(width), float64(srcW), float64(srcH), innerRadii)
+				sr = float64(src.Pix[i])
+				sg = float64(src.Pix[i+1])
+				sb = float64(src.Pix[i+2])
+				sa = float64(src.Pix[i+3]) / 255 * coverage
+			}
+
+			// Composite the image over the border color
+			a := sa + ba*(1-sa)
+			if a <= 0 {
+				continue
+			}
+
+			o := result.PixOffset(x, y)
+			result.Pix[o] = uint8(math.Round((sr*sa + br*ba*(1-sa)) / a))
+			result.Pix[o+1] = uint8(math.Round((sg*sa + bg*ba*(1-sa)) / a))
+			result.Pix[o+2] = uint8(math.Round((sb*sa + bb*ba*(1-sa)) / a))
+			result.Pix[o+3] = uint8(math.Round(a * 255 * roundedRectCoverage(px, py, float64(w), float64(h), outerRadii)))
+		}
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *BorderManipulator) Schema() Schema {
+	return Schema{
+		Description: "Adds a solid border around the image",
+		Params: []ParamSchema{
+			Param("w", ParamInt, "border width in pixels").Range(1, 20000),
+			Param("c", ParamColor, "border color").WithDefault("black"),
+			Param("r", ParamFloat, "radius in pixels of the outer corners").Range(0, 20000),
+		},
+	}
+}
+
+// NewBorderManipulator returns a new border Manipulator
+func NewBorderManipulator(cfg *config.Config) *BorderManipulator {
+	return &BorderManipulator{Cfg: cfg}
+}
@@ -38,6 +38,16 @@ func (manipulator *BrightnessManipulator) Execute(c *fiber.Ctx, params map[strin
 	return img, nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *BrightnessManipulator) Schema() Schema {
+	return Schema{
+		Description: "Adjusts the brightness of the image",
+		Params: []ParamSchema{
+			Param("v", ParamFloat, "brightness change in percentages").Range(-100, 100),
+		},
+	}
+}
+
 // NewBrightnessManipulator returns a new brightness Manipulator
 func NewBrightnessManipulator(cfg *config.Config) *BrightnessManipulator {
 	return &BrightnessManipulator{}
@@ -0,0 +1,147 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"github.com/erans/thumbla/config"
+)
+
+const (
+	// canvasFillColor fills the new canvas area with a solid (or transparent) color
+	canvasFillColor = "color"
+	// canvasFillEdge repeats the edge pixels of the image into the new canvas area
+	canvasFillEdge = "edge"
+	// canvasFillMirror mirrors the image into the new canvas area
+	canvasFillMirror = "mirror"
+)
+
+// parseLength parses a length given either in pixels or as a percentage of total (i.e. "10%")
+func parseLength(value string, total int) (int, error) {
+	if strings.HasSuffix(value, "%") {
+		percentage, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid percentage value '%s'", value)
+		}
+		return int(float64(total) * percentage / 100.0), nil
+	}
+
+	pixels, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pixel value '%s'", value)
+	}
+
+	return pixels, nil
+}
+
+// parseCanvasFill parses the shared "bg" and "mode" parameters used by manipulators that grow the canvas
+func parseCanvasFill(params map[string]string) (string, color.NRGBA, error) {
+	var mode = canvasFillColor
+	var bg = namedColors["transparent"]
+	var err error
+
+	if v, ok := params["bg"]; ok {
+		if bg, err = parseColor(v); err != nil {
+			return "", bg, err
+		}
+	}
+
+	if v, ok := params["mode"]; ok {
+		if v != canvasFillColor && v != canvasFillEdge && v != canvasFillMirror {
+			return "", bg, fmt.Errorf("unknown fill mode '%s'", v)
+		}
+		mode = v
+	}
+
+	return mode, bg, nil
+}
+
+// canvasFillModeParam describes the shared "mode" parameter parsed by parseCanvasFill
+func canvasFillModeParam() ParamSchema {
+	return EnumParam("mode", "fill mode of the new canvas area", canvasFillColor, canvasFillEdge, canvasFillMirror).WithDefault(canvasFillColor)
+}
+
+// mirrorCoord maps a coordinate outside of [0, size) back into it by mirroring
+func mirrorCoord(v, size int) int {
+	if size == 1 {
+		return 0
+	}
+
+	period := 2 * size
+	v %= period
+	if v < 0 {
+		v += period
+	}
+
+	if v >= size {
+		v = period - v - 1
+	}
+
+	return v
+}
+
+// clampInt limits v to [min, max]
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// checkCanvasSize returns an error if an image of the given size, about to be allocated by a manipulator, is out of
+// the range allowed by the max image dimension. Parameter ranges alone can't guarantee that, as the result size
+// usually depends on the size of the source image as well
+func checkCanvasSize(cfg *config.Config, size image.Point) error {
+	maxDimension := cfg.GetMaxImageDimension()
+	if size.X < 1 || size.Y < 1 || size.X > maxDimension || size.Y > maxDimension {
+		return fmt.Errorf("result image dimensions (%dx%d) are out of the allowed range (1x1-%dx%d)", size.X, size.Y, maxDimension, maxDimension)
+	}
+	return nil
+}
+
+// extendCanvas places the image at the specified position of a new canvas of the given size and fills the
+// remaining area according to the fill mode
+func extendCanvas(img image.Image, size image.Point, position image.Point, mode string, bg color.NRGBA) *image.NRGBA {
+	src := toNRGBA(img)
+	srcW := src.Bounds().Dx()
+	srcH := src.Bounds().Dy()
+
+	result := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+
+	if mode == canvasFillColor || srcW == 0 || srcH == 0 {
+		draw.Draw(result, result.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+		draw.Draw(result, image.Rectangle{Min: position, Max: position.Add(image.Pt(srcW, srcH))}, src, image.Point{}, draw.Src)
+		return result
+	}
+
+	for y := 0; y < size.Y; y++ {
+		sy := y - position.Y
+		if mode == canvasFillEdge {
+			sy = clampInt(sy, 0, srcH-1)
+		} else {
+			sy = mirrorCoord(sy, srcH)
+		}
+
+		for x := 0; x < size.X; x++ {
+			sx := x - position.X
+			if mode == canvasFillEdge {
+				sx = clampInt(sx, 0, srcW-1)
+			} else {
+				sx = mirrorCoord(sx, srcW)
+			}
+
+			i := src.PixOffset(sx, sy)
+			o := result.PixOffset(x, y)
+			copy(result.Pix[o:o+4], src.Pix[i:i+4])
+		}
+	}
+
+	return result
+}
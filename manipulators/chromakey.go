@@ -0,0 +1,135 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ChromaKeyManipulator makes pixels close to a key color transparent
+//
+// Supported parameters:
+// - c (color) - key color (default 00ff00)
+// - tol (float 0-100) - color distance in percentages under which pixels become fully transparent (default 20)
+// - soft (float 0-100) - additional color distance over which the alpha ramps up to create soft edges (default 10)
+// - spill (float 0-100) - strength of the key color spill suppression on the remaining pixels (default 50)
+type ChromaKeyManipulator struct {
+}
+
+// colorDistance returns the distance between two colors normalized to 0-1
+func colorDistance(r, g, b float64, c color.NRGBA) float64 {
+	dr := r - float64(c.R)
+	dg := g - float64(c.G)
+	db := b - float64(c.B)
+	return math.Sqrt(dr*dr+dg*dg+db*db) / (math.Sqrt(3) * 255)
+}
+
+// colorRangeWeight returns 1 for distances within the tolerance, 0 for distances beyond tolerance + softness and
+// a linear ramp in between
+func colorRangeWeight(distance, tolerance, softness float64) float64 {
+	if distance <= tolerance {
+		return 1
+	}
+
+	if softness <= 0 || distance >= tolerance+softness {
+		return 0
+	}
+
+	return 1 - (distance-tolerance)/softness
+}
+
+// Execute runs the chroma key manipulator
+func (manipulator *ChromaKeyManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var err error
+	var key = color.NRGBA{0, 255, 0, 255}
+	if v, ok := params["c"]; ok {
+		if key, err = parseColor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	tolerance, err := parsePercentageParam(params, "tol", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	softness, err := parsePercentageParam(params, "soft", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	spill, err := parsePercentageParam(params, "spill", 50)
+	if err != nil {
+		return nil, err
+	}
+
+	// Spill is suppressed on the channels that dominate the key color. Neutral keys (white, gray) have no spill
+	keyMax := math.Max(float64(key.R), math.Max(float64(key.G), float64(key.B)))
+	keyMin := math.Min(float64(key.R), math.Min(float64(key.G), float64(key.B)))
+	dominant := [3]bool{float64(key.R) == keyMax, float64(key.G) == keyMax, float64(key.B) == keyMax}
+	if keyMax == keyMin {
+		spill = 0
+	}
+
+	result := toNRGBA(img)
+	b := result.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := result.PixOffset(x, y)
+			px := result.Pix[i : i+4 : i+4]
+			if px[3] == 0 {
+				continue
+			}
+
+			channels := [3]float64{float64(px[0]), float64(px[1]), float64(px[2])}
+			weight := colorRangeWeight(colorDistance(channels[0], channels[1], channels[2], key), tolerance, softness)
+
+			if weight >= 1 {
+				px[3] = 0
+				continue
+			}
+
+			if spill > 0 {
+				// Limit the dominant channels of the key color to the strongest of the other channels
+				var limit float64
+				for ch := 0; ch < 3; ch++ {
+					if !dominant[ch] {
+						limit = math.Max(limit, channels[ch])
+					}
+				}
+
+				for ch := 0; ch < 3; ch++ {
+					if dominant[ch] && channels[ch] > limit {
+						channels[ch] -= (channels[ch] - limit) * spill
+						px[ch] = uint8(math.Round(channels[ch]))
+					}
+				}
+			}
+
+			px[3] = uint8(math.Round(float64(px[3]) * (1 - weight)))
+		}
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *ChromaKeyManipulator) Schema() Schema {
+	return Schema{
+		Description: "Makes pixels close to a key color transparent",
+		Params: []ParamSchema{
+			Param("c", ParamColor, "key color").WithDefault("00ff00"),
+			Param("tol", ParamFloat, "color distance in percentages of fully transparent pixels").Range(0, 100).WithDefault("20"),
+			Param("soft", ParamFloat, "color distance in percentages of the soft edges").Range(0, 100).WithDefault("10"),
+			Param("spill", ParamFloat, "spill suppression strength in percentages").Range(0, 100).WithDefault("50"),
+		},
+	}
+}
+
+// NewChromaKeyManipulator returns a new chroma key Manipulator
+func NewChromaKeyManipulator(cfg *config.Config) *ChromaKeyManipulator {
+	return &ChromaKeyManipulator{}
+}
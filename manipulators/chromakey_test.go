@@ -0,0 +1,71 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestChromaKeyManipulator(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewChromaKeyManipulator(cfg)
+
+	// Green backdrop with a red subject and a green tinted gray pixel
+	testImg := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	testImg.Set(0, 0, color.NRGBA{10, 250, 5, 255})
+	testImg.Set(1, 0, color.NRGBA{200, 30, 30, 255})
+	testImg.Set(2, 0, color.NRGBA{100, 140, 100, 255})
+
+	var c *fiber.Ctx
+	result, err := manipulator.Execute(c, map[string]string{"c": "00ff00", "tol": "10", "soft": "5"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if a := alphaAt(result, 0, 0); a != 0 {
+		t.Errorf("expected backdrop to be transparent, got alpha %d", a)
+	}
+
+	if a := alphaAt(result, 1, 0); a != 255 {
+		t.Errorf("expected subject to stay opaque, got alpha %d", a)
+	}
+
+	// Spill suppression should pull the green channel towards the other channels
+	if _, g, _, _ := result.At(2, 0).RGBA(); g>>8 >= 140 {
+		t.Errorf("expected green spill to be suppressed, got g=%d", g>>8)
+	}
+
+	if _, err := manipulator.Execute(c, map[string]string{"tol": "150"}, testImg); err == nil {
+		t.Error("Expected error for invalid tolerance")
+	}
+}
+
+func TestReplaceColorManipulator(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewReplaceColorManipulator(cfg)
+
+	testImg := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	testImg.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	testImg.Set(1, 0, color.NRGBA{0, 0, 255, 255})
+
+	var c *fiber.Ctx
+	result, err := manipulator.Execute(c, map[string]string{"from": "ff0000", "to": "00ff00"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := color.NRGBAModel.Convert(result.At(0, 0)).(color.NRGBA); got != (color.NRGBA{0, 255, 0, 255}) {
+		t.Errorf("expected replaced color, got %v", got)
+	}
+
+	if got := color.NRGBAModel.Convert(result.At(1, 0)).(color.NRGBA); got != (color.NRGBA{0, 0, 255, 255}) {
+		t.Errorf("expected untouched color, got %v", got)
+	}
+
+	if _, err := manipulator.Execute(c, map[string]string{"from": "ff0000"}, testImg); err == nil {
+		t.Error("Expected error for missing to parameter")
+	}
+}
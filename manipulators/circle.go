@@ -0,0 +1,39 @@
+package manipulators
+
+import (
+	"image"
+	"math"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CircleManipulator masks the image with the largest inscribed circle, making the area outside it transparent
+type CircleManipulator struct {
+}
+
+// Execute runs the circle manipulator and masks the image with a circle
+func (manipulator *CircleManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	result := toNRGBA(img)
+	w := float64(result.Bounds().Dx())
+	h := float64(result.Bounds().Dy())
+	radius := math.Min(w, h) / 2
+
+	applyCoverage(result, func(px, py float64) float64 {
+		return ellipseCoverage(px, py, w/2, h/2, radius, radius)
+	})
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *CircleManipulator) Schema() Schema {
+	return Schema{
+		Description: "Masks the image with the largest inscribed circle",
+	}
+}
+
+// NewCircleManipulator returns a new circle mask Manipulator
+func NewCircleManipulator(cfg *config.Config) *CircleManipulator {
+	return &CircleManipulator{}
+}
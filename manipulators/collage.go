@@ -0,0 +1,268 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"github.com/anthonynsimon/bild/transform"
+)
+
+const (
+	// CollageLayoutGrid lays the images out in equally sized cells
+	CollageLayoutGrid = "grid"
+	// CollageLayoutMosaic lays the images out in justified rows, keeping their proportions
+	CollageLayoutMosaic = "mosaic"
+
+	collageFitCover   = "cover"
+	collageFitContain = "contain"
+)
+
+// CollageOptions controls how ComposeCollage lays out the images. MaxDimension limits the width and height of
+// the collage, 0 means no limit
+type CollageOptions struct {
+	Layout       string
+	Width        int
+	Height       int
+	Columns      int
+	Rows         int
+	Gap          int
+	Fit          string
+	Background   color.NRGBA
+	MaxDimension int
+}
+
+// ParseCollageOptions parses the collage parameters
+//
+// Supported parameters:
+// - layout (string) - grid (default) or mosaic
+// - w (int) - width of the collage (default 1000)
+// - h (int) - height of the collage. Defaults to square grid cells. Ignored by the mosaic layout
+// - cols, rows (int) - grid size, up to the number of images. Defaults to the smallest square grid that fits all images. Mosaic uses rows only
+// - gap (int) - gutter in pixels between the images
+// - fit (string) - cover (default) crops the images to fill their cells, contain fits them inside the cells
+// - bg (color) - background color (default white)
+func ParseCollageOptions(params map[string]string, count int) (*CollageOptions, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("collage requires at least one image")
+	}
+
+	var err error
+	opts := &CollageOptions{
+		Layout:     CollageLayoutGrid,
+		Width:      1000,
+		Fit:        collageFitCover,
+		Background: namedColors["white"],
+	}
+
+	if v, ok := params["layout"]; ok {
+		if v != CollageLayoutGrid && v != CollageLayoutMosaic {
+			return nil, fmt.Errorf("unknown collage layout '%s'", v)
+		}
+		opts.Layout = v
+	}
+
+	if v, ok := params["fit"]; ok {
+		if v != collageFitCover && v != collageFitContain {
+			return nil, fmt.Errorf("unknown collage fit '%s'", v)
+		}
+		opts.Fit = v
+	}
+
+	ints := map[string]*int{
+		"w":    &opts.Width,
+		"h":    &opts.Height,
+		"cols": &opts.Columns,
+		"rows": &opts.Rows,
+		"gap":  &opts.Gap,
+	}
+
+	for name, target := range ints {
+		if v, ok := params[name]; ok {
+			if *target, err = strconv.Atoi(v); err != nil || *target < 0 {
+				return nil, fmt.Errorf("invalid collage %s value '%s'", name, v)
+			}
+		}
+	}
+
+	if v, ok := params["bg"]; ok {
+		if opts.Background, err = parseColor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Columns == 0 && opts.Rows == 0 {
+		opts.Columns = int(math.Ceil(math.Sqrt(float64(count))))
+	}
+
+	if opts.Columns == 0 {
+		opts.Columns = int(math.Ceil(float64(count) / float64(opts.Rows)))
+	}
+
+	if opts.Rows == 0 {
+		opts.Rows = int(math.Ceil(float64(count) / float64(opts.Columns)))
+	}
+
+	// Cells beyond the number of images would only add empty space
+	opts.Columns = min(opts.Columns, count)
+	opts.Rows = min(opts.Rows, count)
+
+	if opts.Width <= 0 {
+		return nil, fmt.Errorf("collage width must be positive")
+	}
+
+	return opts, nil
+}
+
+// checkSize returns an error if a collage of the given size is out of the allowed range
+func (opts *CollageOptions) checkSize(width, height int) error {
+	if opts.MaxDimension > 0 && (width > opts.MaxDimension || height > opts.MaxDimension) {
+		return fmt.Errorf("collage dimensions (%dx%d) exceed maximum allowed size (%dx%d)", width, height, opts.MaxDimension, opts.MaxDimension)
+	}
+	return nil
+}
+
+// fitIntoCell fits the image into the cell, either cropping it to fill the cell (cover) or fitting it inside
+// the cell (contain). The returned rectangle is where the image should be drawn relative to the cell
+func fitIntoCell(img image.Image, cellW, cellH int, fit string) (image.Image, image.Rectangle) {
+	b := img.Bounds()
+
+	if fit == collageFitContain {
+		scale := math.Min(float64(cellW)/float64(b.Dx()), float64(cellH)/float64(b.Dy()))
+		w := clampInt(int(math.Round(float64(b.Dx())*scale)), 1, cellW)
+		h := clampInt(int(math.Round(float64(b.Dy())*scale)), 1, cellH)
+		resized := transform.Resize(img, w, h, transform.Linear)
+		return resized, gravityRect("center", image.Rect(0, 0, cellW, cellH), image.Pt(w, h), image.Point{})
+	}
+
+	// Crop the center of the image to the proportions of the cell before resizing, so the image is never
+	// scaled beyond the cell size however far its proportions are from the cell's
+	size := b.Size()
+	if b.Dx()*cellH > b.Dy()*cellW {
+		size.X = clampInt(int(math.Round(float64(b.Dy())*float64(cellW)/float64(cellH))), 1, b.Dx())
+	} else {
+		size.Y = clampInt(int(math.Round(float64(b.Dx())*float64(cellH)/float64(cellW))), 1, b.Dy())
+	}
+
+	cropped := image.NewNRGBA(image.Rectangle{Max: size})
+	draw.Draw(cropped, cropped.Bounds(), img, gravityRect("center", b, size, image.Point{}).Min, draw.Src)
+
+	return transform.Resize(cropped, cellW, cellH, transform.Linear), image.Rect(0, 0, cellW, cellH)
+}
+
+// ComposeCollage lays out the images on a single canvas
+func ComposeCollage(images []image.Image, opts *CollageOptions) (image.Image, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("collage requires at least one image")
+	}
+
+	if opts.Layout == CollageLayoutMosaic {
+		return composeMosaic(images, opts)
+	}
+
+	cellW := (opts.Width - opts.Gap*(opts.Columns-1)) / opts.Columns
+	if cellW <= 0 {
+		return nil, fmt.Errorf("collage width is too small for %d columns", opts.Columns)
+	}
+
+	height := opts.Height
+	if height == 0 {
+		height = cellW*opts.Rows + opts.Gap*(opts.Rows-1)
+	}
+
+	cellH := (height - opts.Gap*(opts.Rows-1)) / opts.Rows
+	if cellH <= 0 {
+		return nil, fmt.Errorf("collage height is too small for %d rows", opts.Rows)
+	}
+
+	if err := opts.checkSize(opts.Width, height); err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, opts.Width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+
+	for i, img := range images {
+		if i >= opts.Columns*opts.Rows {
+			break
+		}
+
+		col := i % opts.Columns
+		row := i / opts.Columns
+		origin := image.Pt(col*(cellW+opts.Gap), row*(cellH+opts.Gap))
+
+		cell, r := fitIntoCell(img, cellW, cellH, opts.Fit)
+		draw.Draw(canvas, r.Add(origin), cell, cell.Bounds().Min, draw.Over)
+	}
+
+	return canvas, nil
+}
+
+// composeMosaic lays out the images in justified rows. Every row fills the collage width and its height
+// is derived from the proportions of the images in it
+func composeMosaic(images []image.Image, opts *CollageOptions) (image.Image, error) {
+	rows := opts.Rows
+	if rows > len(images) {
+		rows = len(images)
+	}
+
+	perRow := int(math.Ceil(float64(len(images)) / float64(rows)))
+
+	type placement struct {
+		img  image.Image
+		rect image.Rectangle
+	}
+
+	var placements []placement
+	y := 0
+	for start := 0; start < len(images); start += perRow {
+		end := start + perRow
+		if end > len(images) {
+			end = len(images)
+		}
+		row := images[start:end]
+
+		var ratios float64
+		for _, img := range row {
+			ratios += float64(img.Bounds().Dx()) / float64(img.Bounds().Dy())
+		}
+
+		available := opts.Width - opts.Gap*(len(row)-1)
+		rowH := int(math.Round(float64(available) / ratios))
+		if available <= 0 || rowH <= 0 {
+			return nil, fmt.Errorf("collage width is too small for %d images per row", len(row))
+		}
+
+		x := 0
+		for i, img := range row {
+			w := int(math.Round(float64(rowH) * float64(img.Bounds().Dx()) / float64(img.Bounds().Dy())))
+			// The last image absorbs rounding errors so that the row ends exactly at the collage width
+			if i == len(row)-1 {
+				w = opts.Width - x
+			}
+			if w < 1 {
+				w = 1
+			}
+			placements = append(placements, placement{img, image.Rect(x, y, x+w, y+rowH)})
+			x += w + opts.Gap
+		}
+
+		y += rowH + opts.Gap
+		if err := opts.checkSize(opts.Width, y-opts.Gap); err != nil {
+			return nil, err
+		}
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, opts.Width, y-opts.Gap))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+
+	for _, p := range placements {
+		cell, r := fitIntoCell(p.img, p.rect.Dx(), p.rect.Dy(), collageFitCover)
+		draw.Draw(canvas, r.Add(p.rect.Min), cell, cell.Bounds().Min, draw.Over)
+	}
+
+	return canvas, nil
+}
@@ -0,0 +1,43 @@
+package manipulators
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+var namedColors = map[string]color.NRGBA{
+	"transparent": {0, 0, 0, 0},
+	"white":       {255, 255, 255, 255},
+	"black":       {0, 0, 0, 255},
+}
+
+// parseColor parses a color given as a name (white, black, transparent) or as a
+// hex string in the form of RGB, RRGGBB or RRGGBBAA with an optional leading '#'
+func parseColor(value string) (color.NRGBA, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if c, ok := namedColors[value]; ok {
+		return c, nil
+	}
+
+	value = strings.TrimPrefix(value, "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+
+	if len(value) == 6 {
+		value += "ff"
+	}
+
+	if len(value) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color '%s'", value)
+	}
+
+	v, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color '%s'", value)
+	}
+
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
@@ -38,6 +38,16 @@ func (manipulator *ContrastManipulator) Execute(c *fiber.Ctx, params map[string]
 	return img, nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *ContrastManipulator) Schema() Schema {
+	return Schema{
+		Description: "Adjusts the contrast of the image",
+		Params: []ParamSchema{
+			Param("v", ParamFloat, "contrast change in percentages").Range(-100, 100),
+		},
+	}
+}
+
 // NewContrastManipulator returns a new contrast Manipulator
 func NewContrastManipulator(cfg *config.Config) *ContrastManipulator {
 	return &ContrastManipulator{}
@@ -109,6 +109,20 @@ func (manipulator *CropManipulator) Execute(c *fiber.Ctx, params map[string]stri
 	return img, nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *CropManipulator) Schema() Schema {
+	return Schema{
+		Description: "Crops the image",
+		Params: []ParamSchema{
+			Param("x", ParamInt, "left edge in pixels").Range(0, 20000),
+			Param("y", ParamInt, "top edge in pixels").Range(0, 20000),
+			Param("w", ParamInt, "width in pixels").Range(1, 20000),
+			Param("h", ParamInt, "height in pixels").Range(1, 20000),
+			Param("r", ParamString, "rectangle in the form of x0|y0|x1|y1, or w%|h% from the top left corner"),
+		},
+	}
+}
+
 // NewCropManipulator returns a new crop Manipulator
 func NewCropManipulator(cfg *config.Config) *CropManipulator {
 	return &CropManipulator{}
@@ -0,0 +1,90 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// DeblockManipulator smooths the block boundaries left by JPEG compression. As blocks are aligned to the
+// original image, it should run before any resize or crop
+//
+// Supported parameters:
+// - t (int 1-255) - largest step in color levels across a block boundary that is considered an artifact (default 24). Larger steps are kept as real edges
+// - bs (int) - block size in pixels (default 8)
+type DeblockManipulator struct {
+}
+
+// smoothBoundary spreads the step between p0 and q0 over the two pixels on each side of a block boundary when
+// the step is small and both sides are flat
+func smoothBoundary(pix []uint8, p1, p0, q0, q1 int, threshold int) {
+	for ch := 0; ch < 3; ch++ {
+		a, b, c, d := int(pix[p1+ch]), int(pix[p0+ch]), int(pix[q0+ch]), int(pix[q1+ch])
+		step := c - b
+		if step == 0 || absInt(step) >= threshold || absInt(b-a) >= threshold/2+1 || absInt(d-c) >= threshold/2+1 {
+			continue
+		}
+
+		// The outer pixels may differ from the boundary ones and can be pushed beyond the channel range
+		pix[p1+ch] = uint8(clampInt(a+step/6, 0, 255))
+		pix[p0+ch] = uint8(clampInt(b+step/3, 0, 255))
+		pix[q0+ch] = uint8(clampInt(c-step/3, 0, 255))
+		pix[q1+ch] = uint8(clampInt(d-step/6, 0, 255))
+	}
+}
+
+// Execute runs the deblock manipulator
+func (manipulator *DeblockManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var err error
+	var threshold = 24
+	if v, ok := params["t"]; ok {
+		if threshold, err = strconv.Atoi(v); err != nil || threshold < 1 || threshold > 255 {
+			return nil, fmt.Errorf("deblock threshold (t) must be a value between 1 and 255")
+		}
+	}
+
+	var blockSize = 8
+	if v, ok := params["bs"]; ok {
+		if blockSize, err = strconv.Atoi(v); err != nil || blockSize < 4 || blockSize > 64 {
+			return nil, fmt.Errorf("deblock block size (bs) must be a value between 4 and 64")
+		}
+	}
+
+	result := toNRGBA(img)
+	b := result.Bounds()
+
+	// Vertical boundaries
+	for x := blockSize; x+1 < b.Dx(); x += blockSize {
+		for y := 0; y < b.Dy(); y++ {
+			smoothBoundary(result.Pix, result.PixOffset(x-2, y), result.PixOffset(x-1, y), result.PixOffset(x, y), result.PixOffset(x+1, y), threshold)
+		}
+	}
+
+	// Horizontal boundaries
+	for y := blockSize; y+1 < b.Dy(); y += blockSize {
+		for x := 0; x < b.Dx(); x++ {
+			smoothBoundary(result.Pix, result.PixOffset(x, y-2), result.PixOffset(x, y-1), result.PixOffset(x, y), result.PixOffset(x, y+1), threshold)
+		}
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *DeblockManipulator) Schema() Schema {
+	return Schema{
+		Description: "Smooths JPEG compression block boundaries",
+		Params: []ParamSchema{
+			Param("t", ParamInt, "largest step in color levels considered an artifact").Range(1, 255).WithDefault("24"),
+			Param("bs", ParamInt, "block size in pixels").Range(4, 64).WithDefault("8"),
+		},
+	}
+}
+
+// NewDeblockManipulator returns a new deblock Manipulator
+func NewDeblockManipulator(cfg *config.Config) *DeblockManipulator {
+	return &DeblockManipulator{}
+}
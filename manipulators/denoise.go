@@ -0,0 +1,206 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	denoiseModeMedian    = "median"
+	denoiseModeBilateral = "bilateral"
+
+	maxDenoiseRadius = 10
+)
+
+// DenoiseManipulator reduces noise while keeping edges. Best applied before resizing
+//
+// Supported parameters:
+// - mode (string) - median (default) or bilateral
+// - r (int 1-10) - radius in pixels of the filter window (default 1 for median, 2 for bilateral)
+// - s (float) - bilateral only. Color difference (0-255) up to which neighbouring pixels are averaged (default 30)
+type DenoiseManipulator struct {
+}
+
+// medianFilter replaces every pixel with the per channel median of its neighbourhood. The window histograms are
+// updated as the window slides along a row, so each step only adds and removes a column of pixels
+func medianFilter(src *image.NRGBA, radius int) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(b)
+
+	var hist [4][256]int
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		top := max(y-radius, b.Min.Y)
+		bottom := min(y+radius, b.Max.Y-1)
+
+		// updateColumn adds (delta 1) or removes (delta -1) the window pixels of column x
+		updateColumn := func(x, delta int) {
+			for wy := top; wy <= bottom; wy++ {
+				i := src.PixOffset(x, wy)
+				for ch := range hist {
+					hist[ch][src.Pix[i+ch]] += delta
+				}
+			}
+		}
+
+		hist = [4][256]int{}
+		for wx := b.Min.X; wx <= min(b.Min.X+radius, b.Max.X-1); wx++ {
+			updateColumn(wx, 1)
+		}
+
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if x > b.Min.X {
+				if out := x - radius - 1; out >= b.Min.X {
+					updateColumn(out, -1)
+				}
+				if in := x + radius; in < b.Max.X {
+					updateColumn(in, 1)
+				}
+			}
+
+			count := (bottom - top + 1) * (min(x+radius, b.Max.X-1) - max(x-radius, b.Min.X) + 1)
+			o := dst.PixOffset(x, y)
+			for ch := range hist {
+				dst.Pix[o+ch] = histogramMedian(&hist[ch], count)
+			}
+		}
+	}
+
+	return dst
+}
+
+// histogramMedian returns the middle value of the count values in the histogram
+func histogramMedian(hist *[256]int, count int) uint8 {
+	seen := 0
+	for v, n := range hist {
+		seen += n
+		if seen > count/2 {
+			return uint8(v)
+		}
+	}
+	return 255
+}
+
+// bilateralFilter averages every pixel with the neighbouring pixels of a similar color, weighted by their
+// distance and their color difference
+func bilateralFilter(src *image.NRGBA, radius int, rangeSigma float64) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(b)
+
+	spatialSigma := math.Max(1, float64(radius)/2)
+	spatial := make([]float64, (2*radius+1)*(2*radius+1))
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			spatial[(dy+radius)*(2*radius+1)+dx+radius] = math.Exp(-float64(dx*dx+dy*dy) / (2 * spatialSigma * spatialSigma))
+		}
+	}
+
+	var rangeWeights [256 * 3]float64
+	for d := range rangeWeights {
+		rangeWeights[d] = math.Exp(-float64(d*d) / (2 * rangeSigma * rangeSigma))
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := src.PixOffset(x, y)
+			center := src.Pix[c : c+4 : c+4]
+
+			var sum [4]float64
+			var total float64
+			for dy := -radius; dy <= radius; dy++ {
+				wy := y + dy
+				if wy < b.Min.Y || wy >= b.Max.Y {
+					continue
+				}
+				for dx := -radius; dx <= radius; dx++ {
+					wx := x + dx
+					if wx < b.Min.X || wx >= b.Max.X {
+						continue
+					}
+
+					i := src.PixOffset(wx, wy)
+					px := src.Pix[i : i+4 : i+4]
+					diff := absInt(int(px[0])-int(center[0])) + absInt(int(px[1])-int(center[1])) + absInt(int(px[2])-int(center[2]))
+					w := spatial[(dy+radius)*(2*radius+1)+dx+radius] * rangeWeights[diff/3]
+
+					for ch := range sum {
+						sum[ch] += float64(px[ch]) * w
+					}
+					total += w
+				}
+			}
+
+			o := dst.PixOffset(x, y)
+			for ch := range sum {
+				dst.Pix[o+ch] = clampChannel(sum[ch] / total)
+			}
+		}
+	}
+
+	return dst
+}
+
+// absInt returns the absolute value of an integer
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Execute runs the denoise manipulator
+func (manipulator *DenoiseManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var err error
+	var mode = denoiseModeMedian
+	if v, ok := params["mode"]; ok {
+		if v != denoiseModeMedian && v != denoiseModeBilateral {
+			return nil, fmt.Errorf("unknown denoise mode '%s'", v)
+		}
+		mode = v
+	}
+
+	var radius = 1
+	if mode == denoiseModeBilateral {
+		radius = 2
+	}
+	if v, ok := params["r"]; ok {
+		if radius, err = strconv.Atoi(v); err != nil || radius < 1 || radius > maxDenoiseRadius {
+			return nil, fmt.Errorf("denoise radius (r) must be a value between 1 and %d", maxDenoiseRadius)
+		}
+	}
+
+	var sigma = 30.0
+	if v, ok := params["s"]; ok {
+		if sigma, err = strconv.ParseFloat(v, 64); err != nil || sigma <= 0 || sigma > 255 {
+			return nil, fmt.Errorf("denoise color difference (s) must be a value between 0 and 255")
+		}
+	}
+
+	src := toNRGBA(img)
+	if mode == denoiseModeBilateral {
+		return bilateralFilter(src, radius, sigma), nil
+	}
+
+	return medianFilter(src, radius), nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *DenoiseManipulator) Schema() Schema {
+	return Schema{
+		Description: "Reduces noise while keeping edges",
+		Params: []ParamSchema{
+			EnumParam("mode", "filter", denoiseModeMedian, denoiseModeBilateral).WithDefault(denoiseModeMedian),
+			Param("r", ParamInt, "radius in pixels of the filter window").Range(1, maxDenoiseRadius),
+			Param("s", ParamFloat, "bilateral color difference up to which pixels are averaged").Range(0, 255).WithDefault("30"),
+		},
+	}
+}
+
+// NewDenoiseManipulator returns a new denoise Manipulator
+func NewDenoiseManipulator(cfg *config.Config) *DenoiseManipulator {
+	return &DenoiseManipulator{}
+}
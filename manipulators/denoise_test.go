@@ -0,0 +1,164 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDenoiseManipulator(t *testing.T) {
+	manipulator := NewDenoiseManipulator(&config.Config{})
+
+	// Gray image with a hard edge on the right half and a noisy pixel set per test
+	testImg := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			v := uint8(100)
+			if x >= 5 {
+				v = 220
+			}
+			testImg.Set(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+
+	var c *fiber.Ctx
+	tests := []struct {
+		name    string
+		params  map[string]string
+		noise   color.NRGBA
+		wantErr bool
+	}{
+		// Median removes impulse noise while bilateral smooths small variations
+		{"median", map[string]string{}, color.NRGBA{255, 0, 255, 255}, false},
+		{"bilateral", map[string]string{"mode": "bilateral", "r": "2", "s": "20"}, color.NRGBA{120, 80, 120, 255}, false},
+		{"unknown mode", map[string]string{"mode": "gaussian"}, color.NRGBA{}, true},
+		{"invalid radius", map[string]string{"r": "20"}, color.NRGBA{}, true},
+		{"invalid color difference", map[string]string{"mode": "bilateral", "s": "0"}, color.NRGBA{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testImg.Set(2, 2, tt.noise)
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// The noisy pixel should be pulled towards its surroundings
+			noisy := color.NRGBAModel.Convert(result.At(2, 2)).(color.NRGBA)
+			if absInt(int(noisy.G)-100) >= absInt(int(tt.noise.G)-100) {
+				t.Errorf("expected the noisy pixel to be reduced, got %v", noisy)
+			}
+
+			// The edge should be kept
+			left := color.NRGBAModel.Convert(result.At(4, 7)).(color.NRGBA)
+			right := color.NRGBAModel.Convert(result.At(5, 7)).(color.NRGBA)
+			if int(right.R)-int(left.R) < 100 {
+				t.Errorf("expected the edge to be kept, got %v and %v", left, right)
+			}
+		})
+	}
+}
+
+func TestMedianFilter(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	testImg := image.NewNRGBA(image.Rect(0, 0, 23, 17))
+	rnd.Read(testImg.Pix)
+
+	for radius := 1; radius <= 3; radius++ {
+		result := medianFilter(testImg, radius)
+
+		// Compare with the median of the sorted window values
+		b := testImg.Bounds()
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				for ch := 0; ch < 4; ch++ {
+					var values []int
+					for wy := max(y-radius, 0); wy <= min(y+radius, b.Dy()-1); wy++ {
+						for wx := max(x-radius, 0); wx <= min(x+radius, b.Dx()-1); wx++ {
+							values = append(values, int(testImg.Pix[testImg.PixOffset(wx, wy)+ch]))
+						}
+					}
+					sort.Ints(values)
+
+					if got := int(result.Pix[result.PixOffset(x, y)+ch]); got != values[len(values)/2] {
+						t.Fatalf("radius %d: expected median %d at (%d, %d) channel %d, got %d", radius, values[len(values)/2], x, y, ch, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDeblockManipulator(t *testing.T) {
+	manipulator := NewDeblockManipulator(&config.Config{})
+
+	// Two flat 8x8 blocks with a small step between them and a strong edge further down
+	testImg := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			v := uint8(100)
+			if x >= 8 {
+				v = 112
+			}
+			if y >= 8 {
+				v += 120
+			}
+			testImg.Set(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+
+	var c *fiber.Ctx
+	result, err := manipulator.Execute(c, map[string]string{}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	left := color.NRGBAModel.Convert(result.At(7, 2)).(color.NRGBA)
+	right := color.NRGBAModel.Convert(result.At(8, 2)).(color.NRGBA)
+	if step := int(right.R) - int(left.R); step >= 12 || step <= 0 {
+		t.Errorf("expected the block step to be smoothed, got %d", step)
+	}
+
+	top := color.NRGBAModel.Convert(result.At(2, 7)).(color.NRGBA)
+	bottom := color.NRGBAModel.Convert(result.At(2, 8)).(color.NRGBA)
+	if step := int(bottom.R) - int(top.R); step != 120 {
+		t.Errorf("expected the strong edge to be kept, got a step of %d", step)
+	}
+
+	if _, err := manipulator.Execute(c, map[string]string{"t": "0"}, testImg); err == nil {
+		t.Errorf("expected an error for an invalid threshold")
+	}
+
+	// Saturated pixels on both sides of a block boundary must not wrap around
+	saturated := image.NewNRGBA(image.Rect(0, 0, 16, 2))
+	for x := 0; x < 16; x++ {
+		bright, dark := uint8(255), uint8(0)
+		if x == 7 {
+			bright, dark = 243, 12
+		}
+		saturated.Set(x, 0, color.NRGBA{bright, bright, bright, 255})
+		saturated.Set(x, 1, color.NRGBA{dark, dark, dark, 255})
+	}
+
+	result, err = manipulator.Execute(c, map[string]string{}, saturated)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	for x := 6; x <= 9; x++ {
+		bright := color.NRGBAModel.Convert(result.At(x, 0)).(color.NRGBA)
+		dark := color.NRGBAModel.Convert(result.At(x, 1)).(color.NRGBA)
+		if bright.R < 240 || dark.R > 15 {
+			t.Errorf("expected saturated pixels to stay saturated at x=%d, got %d and %d", x, bright.R, dark.R)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxDPR is the highest supported device pixel ratio
+const MaxDPR = 5.0
+
+// dprParams lists the pixel valued parameters of every manipulator that are multiplied by the device pixel ratio.
+// Values may hold several numbers separated by '|' or ';'. Percentages and non numeric values are kept as is
+var dprParams = map[string][]string{
+	"resize":      {"w", "h"},
+	"fit":         {"w", "h"},
+	"crop":        {"x", "y", "w", "h", "r"},
+	"pad":         {"t", "r", "b", "l", "p"},
+	"extend":      {"w", "h"},
+	"paste":       {"x", "y", "gap"},
+	"border":      {"w", "r"},
+	"round":       {"r", "tl", "tr", "br", "bl"},
+	"trim":        {"p"},
+	"shadow":      {"x", "y", "blur"},
+	"redact":      {"s", "rects"},
+	"perspective": {"to", "w", "h"},
+}
+
+// dprCanvasSizeManipulators lists the manipulators whose w and h parameters set the size of the result canvas
+var dprCanvasSizeManipulators = map[string]bool{
+	"extend":      true,
+	"perspective": true,
+}
+
+// ParseDPR parses a device pixel ratio between 1 and MaxDPR
+func ParseDPR(value string) (float64, error) {
+	dpr, err := strconv.ParseFloat(value, 64)
+	if err != nil || dpr < 1 || dpr > MaxDPR {
+		return 0, fmt.Errorf("dpr must be a number between 1 and %g", MaxDPR)
+	}
+	return dpr, nil
+}
+
+// dprCap returns the highest device pixel ratio a resize or fit manipulator can use without upscaling beyond
+// the resolution of the image, or 0 if the parameters don't limit it
+func dprCap(name string, params map[string]string, size image.Point) float64 {
+	var ratios []float64
+	if w, err := strconv.ParseFloat(params["w"], 64); err == nil && w > 0 {
+		ratios = append(ratios, float64(size.X)/w)
+	}
+	if h, err := strconv.ParseFloat(params["h"], 64); err == nil && h > 0 {
+		ratios = append(ratios, float64(size.Y)/h)
+	}
+
+	if len(ratios) == 0 {
+		return 0
+	}
+
+	// Resize produces exactly the requested size, while fit (and resize in max mode) is limited by the tighter
+	// of the two sides
+	limit := ratios[0]
+	for _, r := range ratios[1:] {
+		if name == "fit" || params["mode"] == resizeModeMax {
+			limit = math.Max(limit, r)
+		} else {
+			limit = math.Min(limit, r)
+		}
+	}
+
+	return limit
+}
+
+// dprSizeCap returns the highest device pixel ratio that keeps the canvas size set by the w and h parameters
+// within maxDimension, or 0 if the parameters don't limit it
+func dprSizeCap(params map[string]string, maxDimension int) float64 {
+	var limit float64
+	for _, name := range []string{"w", "h"} {
+		if v, err := strconv.ParseFloat(params[name], 64); err == nil && v > 0 {
+			if r := float64(maxDimension) / v; limit == 0 || r < limit {
+				limit = r
+			}
+		}
+	}
+	return limit
+}
+
+// scaleDPRValue multiplies every number in the value by the device pixel ratio
+func scaleDPRValue(value string, dpr float64) string {
+	var sb strings.Builder
+	start := 0
+	for i := 0; i <= len(value); i++ {
+		if i < len(value) && value[i] != '|' && value[i] != ';' {
+			continue
+		}
+
+		token := value[start:i]
+		if v, err := strconv.ParseFloat(token, 64); err == nil && !strings.HasSuffix(token, "%") {
+			token = strconv.Itoa(int(math.Round(v * dpr)))
+		}
+		sb.WriteString(token)
+
+		if i < len(value) {
+			sb.WriteByte(value[i])
+		}
+		start = i + 1
+	}
+	return sb.String()
+}
+
+// ScaleParamsForDPR returns a copy of the manipulator parameters with its pixel valued parameters multiplied by
+// the device pixel ratio. Resize and fit lower the ratio so that the image is never upscaled beyond its own
+// resolution, and manipulators that set the canvas size lower it so that the canvas stays within maxDimension.
+// The returned ratio is the one that was applied and should be used for the rest of the chain. As the scaled
+// values may exceed the limits the parameters were validated against, they are validated again
+func ScaleParamsForDPR(name string, params map[string]string, dpr float64, img image.Image, maxDimension int) (map[string]string, float64, error) {
+	names, ok := dprParams[name]
+	if !ok || dpr == 1 {
+		return params, dpr, nil
+	}
+
+	var limit float64
+	if name == "resize" || name == "fit" {
+		limit = dprCap(name, params, img.Bounds().Size())
+	} else if dprCanvasSizeManipulators[name] {
+		limit = dprSizeCap(params, maxDimension)
+	}
+	if limit > 0 && limit < dpr {
+		dpr = math.Max(1, limit)
+	}
+
+	scaled := make(map[string]string, len(params))
+	for k, v := range params {
+		scaled[k] = v
+	}
+
+	for _, n := range names {
+		if v, ok := scaled[n]; ok {
+			scaled[n] = scaleDPRValue(v, dpr)
+		}
+	}
+
+	if manipulator := GetManipulatorByName(name); manipulator != nil {
+		if err := manipulator.Schema().Validate(scaled); err != nil {
+			return nil, dpr, fmt.Errorf("invalid parameters at dpr %g: %v", dpr, err)
+		}
+	}
+
+	return scaled, dpr, nil
+}
@@ -0,0 +1,85 @@
+package manipulators
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+)
+
+func TestScaleParamsForDPR(t *testing.T) {
+	InitManipulators(&config.Config{})
+	img := image.NewNRGBA(image.Rect(0, 0, 1000, 500))
+
+	tests := []struct {
+		name        string
+		manipulator string
+		params      map[string]string
+		dpr         float64
+		wantParams  map[string]string
+		wantDPR     float64
+	}{
+		{"resize", "resize", map[string]string{"w": "200", "r": "lanczos"}, 2, map[string]string{"w": "400", "r": "lanczos"}, 2},
+		{"resize capped", "resize", map[string]string{"w": "400"}, 3, map[string]string{"w": "1000"}, 2.5},
+		{"resize larger than source", "resize", map[string]string{"w": "2000"}, 2, map[string]string{"w": "2000"}, 1},
+		{"fit capped by the wider side", "fit", map[string]string{"w": "500", "h": "500"}, 3, map[string]string{"w": "1000", "h": "1000"}, 2},
+		{"pad keeps percentages", "pad", map[string]string{"t": "10", "l": "5%"}, 2, map[string]string{"t": "20", "l": "5%"}, 2},
+		{"multiple values", "redact", map[string]string{"rects": "1|2|3|4;5|6|7|8", "mode": "blur"}, 2, map[string]string{"rects": "2|4|6|8;10|12|14|16", "mode": "blur"}, 2},
+		{"negative offsets", "shadow", map[string]string{"x": "-3", "opacity": "40"}, 2, map[string]string{"x": "-6", "opacity": "40"}, 2},
+		{"unaffected manipulator", "output", map[string]string{"q": "80"}, 2, map[string]string{"q": "80"}, 2},
+		{"extend capped by the max dimension", "extend", map[string]string{"w": "5000", "h": "2000"}, 3, map[string]string{"w": "10000", "h": "4000"}, 2},
+		{"extend larger than the max dimension", "extend", map[string]string{"w": "20000", "h": "20000"}, 5, map[string]string{"w": "20000", "h": "20000"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, dpr, err := ScaleParamsForDPR(tt.manipulator, tt.params, tt.dpr, img, 10000)
+			if err != nil {
+				t.Fatalf("ScaleParamsForDPR() error = %v", err)
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("params = %v, want %v", params, tt.wantParams)
+			}
+			if dpr != tt.wantDPR {
+				t.Errorf("dpr = %g, want %g", dpr, tt.wantDPR)
+			}
+		})
+	}
+
+	// The original parameters must not be modified
+	params := map[string]string{"w": "10"}
+	ScaleParamsForDPR("resize", params, 2, img, 10000)
+	if params["w"] != "10" {
+		t.Errorf("expected the original parameters to be kept, got %v", params)
+	}
+
+	// Scaled values are validated against the limits of the manipulator
+	for _, tt := range []struct {
+		manipulator string
+		params      map[string]string
+	}{
+		{"pad", map[string]string{"p": "15000"}},
+		{"border", map[string]string{"w": "5000"}},
+		{"shadow", map[string]string{"x": "-300"}},
+		{"redact", map[string]string{"mode": "blur", "s": "400"}},
+	} {
+		if _, _, err := ScaleParamsForDPR(tt.manipulator, tt.params, 5, img, 10000); err == nil {
+			t.Errorf("%s: expected an error for %v scaled beyond its limits", tt.manipulator, tt.params)
+		}
+	}
+}
+
+func TestParseDPR(t *testing.T) {
+	for _, v := range []string{"1", "1.5", "3"} {
+		if _, err := ParseDPR(v); err != nil {
+			t.Errorf("ParseDPR(%q) error = %v", v, err)
+		}
+	}
+
+	for _, v := range []string{"0.5", "6", "abc", ""} {
+		if _, err := ParseDPR(v); err == nil {
+			t.Errorf("ParseDPR(%q) expected an error", v)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/anthonynsimon/bild/adjust"
+	"github.com/anthonynsimon/bild/effect"
+	"github.com/anthonynsimon/bild/segment"
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// effectFunc applies a single effect using its own parameters
+type effectFunc func(params map[string]string, img image.Image) (image.Image, error)
+
+// radiusEffect wraps bild effects that take a radius parameter (r)
+func radiusEffect(def, max float64, f func(image.Image, float64) *image.RGBA) effectFunc {
+	return func(params map[string]string, img image.Image) (image.Image, error) {
+		radius, err := parseFloatParam(params, "r", def, 0.5, max)
+		if err != nil {
+			return nil, err
+		}
+		return f(img, radius), nil
+	}
+}
+
+// simpleEffect wraps bild effects that have no parameters
+func simpleEffect(f func(image.Image) *image.RGBA) effectFunc {
+	return func(params map[string]string, img image.Image) (image.Image, error) {
+		return f(img), nil
+	}
+}
+
+var effectsRegistry = map[string]effectFunc{
+	"edge":      radiusEffect(1, 10, effect.EdgeDetection),
+	"dilate":    radiusEffect(1, 10, effect.Dilate),
+	"erode":     radiusEffect(1, 10, effect.Erode),
+	"emboss":    simpleEffect(effect.Emboss),
+	"sobel":     simpleEffect(effect.Sobel),
+	"sharpen":   simpleEffect(effect.Sharpen),
+	"invert":    simpleEffect(effect.Invert),
+	"grayscale": simpleEffect(effect.Grayscale),
+	"sepia":     simpleEffect(effect.Sepia),
+	"unsharp": func(params map[string]string, img image.Image) (image.Image, error) {
+		radius, err := parseFloatParam(params, "r", 2, 0.5, 50)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := parseFloatParam(params, "amount", 1, 0, 10)
+		if err != nil {
+			return nil, err
+		}
+		return effect.UnsharpMask(img, radius, amount), nil
+	},
+	"posterize": func(params map[string]string, img image.Image) (image.Image, error) {
+		levels, err := parseFloatParam(params, "levels", 4, 2, 64)
+		if err != nil {
+			return nil, err
+		}
+		step := 255 / (math.Floor(levels) - 1)
+		posterize := func(v uint8) uint8 {
+			return clampChannel(math.Round(float64(v)/step) * step)
+		}
+		return adjust.Apply(img, func(c color.RGBA) color.RGBA {
+			// Posterize the straight color so that semi transparent pixels keep their alpha
+			if c.A == 0 {
+				return c
+			}
+			n := color.NRGBAModel.Convert(c).(color.NRGBA)
+			n.R, n.G, n.B = posterize(n.R), posterize(n.G), posterize(n.B)
+			return color.RGBAModel.Convert(n).(color.RGBA)
+		}), nil
+	},
+	"threshold": func(params map[string]string, img image.Image) (image.Image, error) {
+		level, err := parseFloatParam(params, "level", 128, 0, 255)
+		if err != nil {
+			return nil, err
+		}
+		return segment.Threshold(img, uint8(level)), nil
+	},
+}
+
+// EffectManipulator applies one of the stylizing effects
+//
+// Supported parameters:
+// - name (string) - effect name:
+//   - edge, dilate, erode - r (float 0.5-10) radius (default 1)
+//   - unsharp - r (float 0.5-50) radius (default 2), amount (float 0-10) (default 1)
+//   - posterize - levels (int 2-64) number of levels per channel (default 4)
+//   - threshold - level (int 0-255) luminance above which pixels become white (default 128)
+//   - emboss, sobel, sharpen, invert, grayscale, sepia - no parameters
+type EffectManipulator struct {
+}
+
+// Execute runs the effect manipulator
+func (manipulator *EffectManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	name, ok := params["name"]
+	if !ok {
+		return nil, fmt.Errorf("effect requires a name parameter")
+	}
+
+	f, ok := effectsRegistry[name]
+	if !ok {
+		names := make([]string, 0, len(effectsRegistry))
+		for n := range effectsRegistry {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown effect '%s'. Supported effects: %s", name, strings.Join(names, ", "))
+	}
+
+	return f(params, img)
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *EffectManipulator) Schema() Schema {
+	return Schema{
+		Description: "Applies a stylizing effect",
+		Params: []ParamSchema{
+			EnumParam("name", "effect name", slices.Sorted(maps.Keys(effectsRegistry))...).AsRequired(),
+			Param("r", ParamFloat, "radius of the edge, dilate, erode and unsharp effects").Range(0.5, 50),
+			Param("amount", ParamFloat, "unsharp amount").Range(0, 10).WithDefault("1"),
+			Param("levels", ParamInt, "posterize levels per channel").Range(2, 64).WithDefault("4"),
+			Param("level", ParamInt, "threshold luminance").Range(0, 255).WithDefault("128"),
+		},
+	}
+}
+
+// NewEffectManipulator returns a new effect Manipulator
+func NewEffectManipulator(cfg *config.Config) *EffectManipulator {
+	return &EffectManipulator{}
+}
@@ -0,0 +1,70 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestEffectManipulator(t *testing.T) {
+	manipulator := NewEffectManipulator(&config.Config{})
+	testImg := newGradientImage(16, 16, 0, 255, [3]int{})
+
+	var c *fiber.Ctx
+	for name := range effectsRegistry {
+		t.Run(name, func(t *testing.T) {
+			result, err := manipulator.Execute(c, map[string]string{"name": name}, testImg)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if result.Bounds().Size() != testImg.Bounds().Size() {
+				t.Errorf("size = %v, want %v", result.Bounds().Size(), testImg.Bounds().Size())
+			}
+		})
+	}
+
+	errorCases := []map[string]string{
+		{},
+		{"name": "cartoon"},
+		{"name": "edge", "r": "0"},
+		{"name": "unsharp", "amount": "20"},
+		{"name": "posterize", "levels": "1"},
+		{"name": "threshold", "level": "300"},
+	}
+	for _, params := range errorCases {
+		if _, err := manipulator.Execute(c, params, testImg); err == nil {
+			t.Errorf("expected an error for %v", params)
+		}
+	}
+}
+
+func TestEffectManipulator_Levels(t *testing.T) {
+	manipulator := NewEffectManipulator(&config.Config{})
+	testImg := newGradientImage(64, 1, 0, 255, [3]int{})
+
+	var c *fiber.Ctx
+	result, err := manipulator.Execute(c, map[string]string{"name": "posterize", "levels": "2"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	for x := 0; x < 64; x++ {
+		v := color.NRGBAModel.Convert(result.At(x, 0)).(color.NRGBA).R
+		if v != 0 && v != 255 {
+			t.Fatalf("expected only 2 levels, got %d at x=%d", v, x)
+		}
+	}
+
+	// Threshold returns a black and white image
+	result, err = manipulator.Execute(c, map[string]string{"name": "threshold"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if _, ok := result.(*image.Gray); !ok {
+		t.Errorf("expected a grayscale image, got %T", result)
+	}
+}
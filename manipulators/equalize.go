@@ -0,0 +1,176 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	equalizeModeGlobal = "global"
+	equalizeModeCLAHE  = "clahe"
+)
+
+// EqualizeManipulator spreads the luminance histogram of the image, either globally or locally per tile
+// using contrast limited adaptive histogram equalization (CLAHE)
+//
+// Supported parameters:
+// - mode (string) - global (default) or clahe
+// - s (float 0-100) - strength in percentages with which the correction is applied (default 100)
+// - tiles (int 1-64) - number of tiles per row and column for clahe (default 8)
+// - limit (float) - clahe contrast limit as a multiple of the average histogram bin (default 2)
+type EqualizeManipulator struct {
+}
+
+// equalizationTable builds a lookup table that maps every luma value to its equalized value.
+// When limit is positive, histogram bins are clipped at limit times the average bin and the excess is
+// redistributed across all bins
+func equalizationTable(hist [256]int, total int, limit float64) [256]float64 {
+	var table [256]float64
+	if total == 0 {
+		for v := range table {
+			table[v] = float64(v)
+		}
+		return table
+	}
+
+	counts := make([]float64, 256)
+	for v, n := range hist {
+		counts[v] = float64(n)
+	}
+
+	if limit > 0 {
+		clipLimit := math.Max(1, limit*float64(total)/256)
+		var excess float64
+		for v := range counts {
+			if counts[v] > clipLimit {
+				excess += counts[v] - clipLimit
+				counts[v] = clipLimit
+			}
+		}
+		for v := range counts {
+			counts[v] += excess / 256
+		}
+	}
+
+	var cdf, cdfMin float64
+	for _, n := range counts {
+		if n > 0 {
+			cdfMin = n
+			break
+		}
+	}
+
+	for v, n := range counts {
+		cdf += n
+		if float64(total) <= cdfMin {
+			table[v] = float64(v)
+			continue
+		}
+		table[v] = math.Max(0, (cdf-cdfMin)/(float64(total)-cdfMin)*255)
+	}
+
+	return table
+}
+
+// Execute runs the equalize manipulator
+func (manipulator *EqualizeManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var err error
+	var mode = equalizeModeGlobal
+	if v, ok := params["mode"]; ok {
+		if v != equalizeModeGlobal && v != equalizeModeCLAHE {
+			return nil, fmt.Errorf("unknown equalization mode '%s'", v)
+		}
+		mode = v
+	}
+
+	strength, err := parsePercentageParam(params, "s", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	var tiles = 8
+	if v, ok := params["tiles"]; ok {
+		if tiles, err = strconv.Atoi(v); err != nil || tiles < 1 || tiles > 64 {
+			return nil, fmt.Errorf("tiles must be a value between 1 and 64")
+		}
+	}
+
+	var limit = 2.0
+	if v, ok := params["limit"]; ok {
+		if limit, err = strconv.ParseFloat(v, 64); err != nil || limit < 1 {
+			return nil, fmt.Errorf("limit must be a number greater than or equal to 1")
+		}
+	}
+
+	result := toNRGBA(img)
+	b := result.Bounds()
+
+	if mode == equalizeModeGlobal {
+		hist, total := luminanceHistogram(result, b)
+		table := equalizationTable(hist, total, 0)
+		remapLuminance(result, strength, func(x, y int, luma float64) float64 {
+			return table[clampChannel(luma)]
+		})
+		return result, nil
+	}
+
+	tilesX := int(math.Min(float64(tiles), float64(b.Dx())))
+	tilesY := int(math.Min(float64(tiles), float64(b.Dy())))
+	tileW := float64(b.Dx()) / float64(tilesX)
+	tileH := float64(b.Dy()) / float64(tilesY)
+
+	tables := make([][256]float64, tilesX*tilesY)
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			tile := image.Rect(
+				b.Min.X+tx*b.Dx()/tilesX, b.Min.Y+ty*b.Dy()/tilesY,
+				b.Min.X+(tx+1)*b.Dx()/tilesX, b.Min.Y+(ty+1)*b.Dy()/tilesY,
+			)
+			hist, total := luminanceHistogram(result, tile)
+			tables[ty*tilesX+tx] = equalizationTable(hist, total, limit)
+		}
+	}
+
+	// Locate the pixel between the centers of its neighbouring tiles
+	neighbours := func(pos float64, size float64, count int) (int, int, float64) {
+		f := pos/size - 0.5
+		t0 := clampInt(int(math.Floor(f)), 0, count-1)
+		t1 := clampInt(t0+1, 0, count-1)
+		return t0, t1, math.Max(0, math.Min(1, f-float64(t0)))
+	}
+
+	remapLuminance(result, strength, func(x, y int, luma float64) float64 {
+		v := clampChannel(luma)
+		tx0, tx1, wx := neighbours(float64(x-b.Min.X)+0.5, tileW, tilesX)
+		ty0, ty1, wy := neighbours(float64(y-b.Min.Y)+0.5, tileH, tilesY)
+
+		top := tables[ty0*tilesX+tx0][v]*(1-wx) + tables[ty0*tilesX+tx1][v]*wx
+		bottom := tables[ty1*tilesX+tx0][v]*(1-wx) + tables[ty1*tilesX+tx1][v]*wx
+		return top*(1-wy) + bottom*wy
+	})
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *EqualizeManipulator) Schema() Schema {
+	return Schema{
+		Description: "Equalizes the luminance histogram of the image",
+		Params: []ParamSchema{
+			EnumParam("mode", "global or adaptive (clahe) equalization", "global", "clahe").WithDefault("global"),
+			Param("s", ParamFloat, "strength in percentages").Range(0, 100).WithDefault("100"),
+			Param("tiles", ParamInt, "clahe tiles per row and column").Range(1, 64).WithDefault("8"),
+			Param("limit", ParamFloat, "clahe contrast limit").AtLeast(1).WithDefault("2"),
+		},
+	}
+}
+
+// NewEqualizeManipulator returns a new equalize Manipulator
+func NewEqualizeManipulator(cfg *config.Config) *EqualizeManipulator {
+	return &EqualizeManipulator{}
+}
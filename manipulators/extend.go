@@ -0,0 +1,92 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExtendManipulator grows the canvas to the specified size and positions the image on it
+//
+// Supported parameters:
+// - w, h (int) - size of the new canvas. A dimension smaller than the image keeps the image dimension
+// - g (string) - gravity of the image on the new canvas (default center). See gravityRegistry for possible values
+// - bg (color) - fill color as a hex value or a color name (default transparent)
+// - mode (string) - fill mode: color (default), edge - repeat edge pixels, mirror - mirror the image
+type ExtendManipulator struct {
+	Cfg *config.Config
+}
+
+// Execute runs the extend manipulator and grows the canvas of the image
+func (manipulator *ExtendManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	mode, bg, err := parseCanvasFill(params)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	size := b.Size()
+
+	if v, ok := params["w"]; ok {
+		w, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid width (w) value")
+		}
+		if w > size.X {
+			size.X = w
+		}
+	}
+
+	if v, ok := params["h"]; ok {
+		h, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid height (h) value")
+		}
+		if h > size.Y {
+			size.Y = h
+		}
+	}
+
+	var gravity = "center"
+	if v, ok := params["g"]; ok {
+		if !isValidGravity(v) {
+			return nil, fmt.Errorf("unknown gravity '%s'", v)
+		}
+		gravity = v
+	}
+
+	if size == b.Size() {
+		return img, nil
+	}
+
+	if err := checkCanvasSize(manipulator.Cfg, size); err != nil {
+		return nil, err
+	}
+
+	position := gravityRect(gravity, image.Rectangle{Max: size}, b.Size(), image.Point{}).Min
+	return extendCanvas(img, size, position, mode, bg), nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *ExtendManipulator) Schema() Schema {
+	return Schema{
+		Description: "Grows the canvas and positions the image on it",
+		Params: []ParamSchema{
+			Param("w", ParamInt, "canvas width in pixels").Range(1, 20000),
+			Param("h", ParamInt, "canvas height in pixels").Range(1, 20000),
+			EnumParam("g", "gravity of the image", slices.Sorted(maps.Keys(gravityRegistry))...).WithDefault("center"),
+			Param("bg", ParamColor, "fill color").WithDefault("transparent"),
+			canvasFillModeParam(),
+		},
+	}
+}
+
+// NewExtendManipulator returns a new extend Manipulator
+func NewExtendManipulator(cfg *config.Config) *ExtendManipulator {
+	return &ExtendManipulator{Cfg: cfg}
+}
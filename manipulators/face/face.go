@@ -26,3 +26,8 @@ func GetDetectorByName(name string) Detector {
 
 	return nil
 }
+
+// RegisterDetector adds a detector under the given name, replacing any detector registered under it
+func RegisterDetector(name string, detector Detector) {
+	detectorRegistry[name] = detector
+}
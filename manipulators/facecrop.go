@@ -7,7 +7,6 @@ import (
 	"image/draw"
 	"log"
 	"math"
-	"net/url"
 	"strconv"
 
 	"golang.org/x/image/font"
@@ -15,9 +14,7 @@ import (
 	"golang.org/x/image/math/fixed"
 
 	"github.com/anthonynsimon/bild/transform"
-	"github.com/erans/thumbla/cache"
 	"github.com/erans/thumbla/config"
-	"github.com/erans/thumbla/manipulators/face"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -95,162 +92,151 @@ func (m *FaceCropManipulator) Execute(c *fiber.Ctx, params map[string]string, im
 		provider = p
 	}
 
-	log.Printf("Try to find detection for provider '%s'", provider)
-	if detector := face.GetDetectorByName(provider); detector != nil {
-		var err error
-		var faces []image.Rectangle
+	faces, err := detectFaces(c, m.Cfg, provider, params, img)
+	if err != nil {
+		log.Printf("Error: %v", err)
+	}
 
-		var imageURL, _ = url.QueryUnescape(c.Params("url"))
+	if len(faces) == 0 {
+		return img, nil
+	}
 
-		var cacheKey = fmt.Sprintf("face-%s-%s", provider, imageURL)
+	// Find bounding rectangle of all faces
+	var minX0 = faces[0].Min.X
+	var minY0 = faces[0].Min.Y
+	var maxX1 = faces[0].Max.X
+	var maxY1 = faces[0].Max.Y
 
-		var useCache = true
-		if v, ok := params["useCache"]; ok {
-			useCache = v != "0"
+	for i, v := range faces {
+		if debug {
+			m.drawRect(v.Min.X, v.Min.Y, v.Max.X, v.Max.Y, 3, color.RGBA{0, 0, 255, 255}, debugImage)
+			m.addLabel(&debugImage, v.Min.X+10, v.Max.Y-10, color.RGBA{0, 0, 255, 255}, fmt.Sprintf("%dx%d F%d", v.Max.X-v.Min.X, v.Max.Y-v.Min.Y, i), true)
 		}
 
-		if !useCache || !cache.GetCache().Contains(cacheKey) {
-			faces, err = detector.Detect(c, m.Cfg, params, img)
-			if err != nil {
-				log.Printf("Error: %v", err)
-			}
-
-			if useCache {
-				cache.GetCache().Set(cacheKey, faces)
-			}
-		} else {
-			faces = cache.GetCache().Get(cacheKey).([]image.Rectangle)
-			log.Printf("Found faces cache")
+		if v.Min.X < minX0 {
+			minX0 = v.Min.X
 		}
 
-		log.Printf("Faces: %v", faces)
-		if len(faces) == 0 {
-			return img, nil
+		if v.Min.Y < minY0 {
+			minY0 = v.Min.Y
 		}
 
-		// Find bounding rectangle of all faces
-		var minX0 = faces[0].Min.X
-		var minY0 = faces[0].Min.Y
-		var maxX1 = faces[0].Max.X
-		var maxY1 = faces[0].Max.Y
-
-		for i, v := range faces {
-			if debug {
-				m.drawRect(v.Min.X, v.Min.Y, v.Max.X, v.Max.Y, 3, color.RGBA{0, 0, 255, 255}, debugImage)
-				m.addLabel(&debugImage, v.Min.X+10, v.Max.Y-10, color.RGBA{0, 0, 255, 255}, fmt.Sprintf("%dx%d F%d", v.Max.X-v.Min.X, v.Max.Y-v.Min.Y, i), true)
-			}
-
-			if v.Min.X < minX0 {
-				minX0 = v.Min.X
-			}
-
-			if v.Min.Y < minY0 {
-				minY0 = v.Min.Y
-			}
-
-			if v.Max.X > maxX1 {
-				maxX1 = v.Max.X
-			}
+		if v.Max.X > maxX1 {
+			maxX1 = v.Max.X
+		}
 
-			if v.Max.Y > maxY1 {
-				maxY1 = v.Max.Y
-			}
+		if v.Max.Y > maxY1 {
+			maxY1 = v.Max.Y
 		}
+	}
 
-		var boundMin = image.Point{X: minX0, Y: minY0}
-		var boundMax = image.Point{X: maxX1, Y: maxY1}
+	var boundMin = image.Point{X: minX0, Y: minY0}
+	var boundMax = image.Point{X: maxX1, Y: maxY1}
 
-		boundWidth := boundMax.X - boundMin.X
-		boundHeight := boundMax.Y - boundMin.Y
-		width := float64(img.Bounds().Dx())
-		height := float64(img.Bounds().Dy())
-		imgRatio := math.Max(width, height) / math.Min(width, height)
+	boundWidth := boundMax.X - boundMin.X
+	boundHeight := boundMax.Y - boundMin.Y
+	width := float64(img.Bounds().Dx())
+	height := float64(img.Bounds().Dy())
+	imgRatio := math.Max(width, height) / math.Min(width, height)
 
-		log.Printf("Bound Min: %v", boundMin)
-		log.Printf("Bound Max: %v", boundMax)
+	log.Printf("Bound Min: %v", boundMin)
+	log.Printf("Bound Max: %v", boundMax)
 
-		if debug {
-			// Draw the bounding rectangle before padding
-			m.drawRect(boundMin.X, boundMin.Y, boundMax.X, boundMax.Y, 4, color.RGBA{0, 255, 0, 255}, debugImage)
-			m.addLabel(&debugImage, boundMin.X+10, boundMin.Y+20, color.RGBA{0, 255, 0, 255}, fmt.Sprintf("%dx%d", boundWidth, boundHeight), true)
-		}
+	if debug {
+		// Draw the bounding rectangle before padding
+		m.drawRect(boundMin.X, boundMin.Y, boundMax.X, boundMax.Y, 4, color.RGBA{0, 255, 0, 255}, debugImage)
+		m.addLabel(&debugImage, boundMin.X+10, boundMin.Y+20, color.RGBA{0, 255, 0, 255}, fmt.Sprintf("%dx%d", boundWidth, boundHeight), true)
+	}
 
-		// Add padding to capture slightly more than the faces
-		var padding = 0.2
-		if v, ok := params["pp"]; ok {
-			padding, _ = strconv.ParseFloat(v, 64)
-		}
+	// Add padding to capture slightly more than the faces
+	var padding = 0.2
+	if v, ok := params["pp"]; ok {
+		padding, _ = strconv.ParseFloat(v, 64)
+	}
 
-		log.Printf("BoundRectWidth=%d  BoundRectHeight=%d", boundWidth, boundHeight)
+	log.Printf("BoundRectWidth=%d  BoundRectHeight=%d", boundWidth, boundHeight)
 
-		widthPadding := int(float64(boundWidth) * padding)
-		heightPadding := int(float64(boundHeight) * padding)
+	widthPadding := int(float64(boundWidth) * padding)
+	heightPadding := int(float64(boundHeight) * padding)
 
-		log.Printf("Width Padding=%d  Height Padding=%d", widthPadding, heightPadding)
+	log.Printf("Width Padding=%d  Height Padding=%d", widthPadding, heightPadding)
 
-		boundMin.X -= widthPadding
-		boundMin.Y -= heightPadding
+	boundMin.X -= widthPadding
+	boundMin.Y -= heightPadding
 
-		boundMax.X += widthPadding
-		boundMax.Y += heightPadding
+	boundMax.X += widthPadding
+	boundMax.Y += heightPadding
 
-		boundWidth = boundMax.X - boundMin.X
-		boundHeight = boundMax.Y - boundMin.Y
+	boundWidth = boundMax.X - boundMin.X
+	boundHeight = boundMax.Y - boundMin.Y
 
-		if debug {
-			// Draw the bounding rectangle after padding
-			m.drawRect(boundMin.X, boundMin.Y, boundMax.X, boundMax.Y, 4, color.RGBA{255, 255, 0, 255}, debugImage)
-			m.addLabel(&debugImage, boundMin.X+10, boundMin.Y+20, color.RGBA{255, 255, 0, 255}, fmt.Sprintf("%dx%d", boundWidth, boundHeight), true)
-		}
+	if debug {
+		// Draw the bounding rectangle after padding
+		m.drawRect(boundMin.X, boundMin.Y, boundMax.X, boundMax.Y, 4, color.RGBA{255, 255, 0, 255}, debugImage)
+		m.addLabel(&debugImage, boundMin.X+10, boundMin.Y+20, color.RGBA{255, 255, 0, 255}, fmt.Sprintf("%dx%d", boundWidth, boundHeight), true)
+	}
 
-		var keepImageOrientation = true
+	var keepImageOrientation = true
 
-		if v, ok := params["kio"]; ok {
-			keepImageOrientation = (v == "1")
-		}
+	if v, ok := params["kio"]; ok {
+		keepImageOrientation = (v == "1")
+	}
+
+	// Keep the face crop with the same image orientation so that it can be used
+	// the same way as the original image was used
+	if keepImageOrientation {
+		if img.Bounds().Dy() > img.Bounds().Dx() && boundWidth > boundHeight {
+			boundHeight = int(float64(boundWidth) / imgRatio)
+			boundRectCenter := image.Point{X: boundMin.X + boundWidth/2, Y: boundMin.Y + boundHeight/2}
+			boundMin.X = boundRectCenter.X - (boundHeight / 2)
+			boundMin.Y = boundRectCenter.Y - (boundWidth / 2)
+			boundMax.X = boundRectCenter.X + (boundHeight / 2)
+			boundMax.Y = boundRectCenter.Y + (boundWidth / 2)
+
+			if boundMin.Y < 0 {
+				boundMax.Y = boundMax.Y + (-1 * boundMin.Y)
+				boundMin.Y = 0
+			}
 
-		// Keep the face crop with the same image orientation so that it can be used
-		// the same way as the original image was used
-		if keepImageOrientation {
-			if img.Bounds().Dy() > img.Bounds().Dx() && boundWidth > boundHeight {
-				boundHeight = int(float64(boundWidth) / imgRatio)
-				boundRectCenter := image.Point{X: boundMin.X + boundWidth/2, Y: boundMin.Y + boundHeight/2}
-				boundMin.X = boundRectCenter.X - (boundHeight / 2)
-				boundMin.Y = boundRectCenter.Y - (boundWidth / 2)
-				boundMax.X = boundRectCenter.X + (boundHeight / 2)
-				boundMax.Y = boundRectCenter.Y + (boundWidth / 2)
-
-				if boundMin.Y < 0 {
-					boundMax.Y = boundMax.Y + (-1 * boundMin.Y)
-					boundMin.Y = 0
-				}
-
-				if boundMax.Y > int(height) {
-					boundMin.Y -= boundMax.Y - int(height)
-					boundMax.Y = int(height)
-				}
+			if boundMax.Y > int(height) {
+				boundMin.Y -= boundMax.Y - int(height)
+				boundMax.Y = int(height)
 			}
 		}
+	}
 
-		log.Printf("Resized Bound Min %v", boundMin)
-		log.Printf("Resized Bound Max %v", boundMax)
+	log.Printf("Resized Bound Min %v", boundMin)
+	log.Printf("Resized Bound Max %v", boundMax)
 
-		//boundWidth = boundMax.X - boundMin.X
-		//boundHeight = boundMax.Y - boundMin.Y
+	//boundWidth = boundMax.X - boundMin.X
+	//boundHeight = boundMax.Y - boundMin.Y
 
-		if debug {
-			// Draw the bounding rectangle after padding
-			m.drawRect(boundMin.X, boundMin.Y, boundMax.X, boundMax.Y, 4, color.RGBA{255, 0, 0, 255}, debugImage)
-			m.addLabel(&debugImage, boundMin.X+10, boundMin.Y+20, color.RGBA{255, 0, 0, 255}, fmt.Sprintf("%dx%d - Final image to be cropped", boundWidth, boundHeight), true)
-		}
+	if debug {
+		// Draw the bounding rectangle after padding
+		m.drawRect(boundMin.X, boundMin.Y, boundMax.X, boundMax.Y, 4, color.RGBA{255, 0, 0, 255}, debugImage)
+		m.addLabel(&debugImage, boundMin.X+10, boundMin.Y+20, color.RGBA{255, 0, 0, 255}, fmt.Sprintf("%dx%d - Final image to be cropped", boundWidth, boundHeight), true)
+	}
 
-		if !debug {
-			return transform.Crop(img, image.Rect(boundMin.X, boundMin.Y, boundMax.X, boundMax.Y)), nil
-		}
+	if !debug {
+		return transform.Crop(img, image.Rect(boundMin.X, boundMin.Y, boundMax.X, boundMax.Y)), nil
 	}
 	return img, nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *FaceCropManipulator) Schema() Schema {
+	return Schema{
+		Description: "Crops the image around the detected faces",
+		Params: []ParamSchema{
+			Param("debug", ParamBool, "draw the detected faces and crop area"),
+			Param("pp", ParamFloat, "factor with which to enlarge the crop area").Range(0, 1).WithDefault("0.2"),
+			Param("provider", ParamString, "face detection provider as defined in the config"),
+			Param("useCache", ParamBool, "cache the face detection result"),
+			Param("kio", ParamBool, "keep the orientation of the original image"),
+		},
+	}
+}
+
 // NewFaceCropManipulator returns a new face crop Manipulator
 func NewFaceCropManipulator(cfg *config.Config) *FaceCropManipulator {
 	return &FaceCropManipulator{DefaultProvider: cfg.FaceAPI.DefaultProvider, Cfg: cfg}
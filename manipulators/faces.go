@@ -0,0 +1,59 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"log"
+
+	"github.com/erans/thumbla/cache"
+	"github.com/erans/thumbla/config"
+	"github.com/erans/thumbla/manipulators/face"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CacheScopeKey is the context key of the part of the cache key that identifies the image a manipulator receives,
+// which is its source image and the chain steps that ran before it. Results derived from the image, such as
+// detected faces, are only cached when it is set
+const CacheScopeKey = "cacheScope"
+
+// detectFaces finds the faces in the image using the specified provider. Results are cached per cache scope
+// unless the useCache parameter is set to 0
+func detectFaces(c *fiber.Ctx, cfg *config.Config, provider string, params map[string]string, img image.Image) ([]image.Rectangle, error) {
+	log.Printf("Try to find detection for provider '%s'", provider)
+	detector := face.GetDetectorByName(provider)
+	if detector == nil {
+		return nil, fmt.Errorf("unknown face detection provider '%s'", provider)
+	}
+
+	// Face coordinates depend on every step that ran before this one, not just on the source image
+	var scope string
+	if c != nil {
+		scope, _ = c.Locals(CacheScopeKey).(string)
+	}
+
+	var cacheKey = fmt.Sprintf("face-%s-%s", provider, scope)
+
+	var useCache = scope != "" && cache.GetCache() != nil
+	if v, ok := params["useCache"]; ok && v == "0" {
+		useCache = false
+	}
+
+	if useCache && cache.GetCache().Contains(cacheKey) {
+		if faces, ok := cache.GetCache().Get(cacheKey).([]image.Rectangle); ok {
+			log.Printf("Found faces cache")
+			return faces, nil
+		}
+	}
+
+	faces, err := detector.Detect(c, cfg, params, img)
+	if err != nil {
+		return nil, err
+	}
+
+	if useCache {
+		cache.GetCache().Set(cacheKey, faces)
+	}
+
+	log.Printf("Faces: %v", faces)
+	return faces, nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"image"
 	"log"
+	"maps"
+	"slices"
 	"strconv"
 
 	"github.com/erans/thumbla/config"
@@ -68,6 +70,18 @@ func (manipulator *FitManipulator) Execute(c *fiber.Ctx, params map[string]strin
 	return resizeManipulator.Execute(c, resizeParams, img)
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *FitManipulator) Schema() Schema {
+	return Schema{
+		Description: "Resizes the image to fit inside the given size",
+		Params: []ParamSchema{
+			Param("w", ParamInt, "maximal width in pixels").Range(1, 20000),
+			Param("h", ParamInt, "maximal height in pixels").Range(1, 20000),
+			EnumParam("r", "resampling filter", slices.Sorted(maps.Keys(resamplingFilters))...).WithDefault("linear"),
+		},
+	}
+}
+
 // NewFitManipulator returns a new fit Manipulator
 func NewFitManipulator(cfg *config.Config) *FitManipulator {
 	return &FitManipulator{}
@@ -0,0 +1,86 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// FlattenManipulator composites the image over a solid background color, removing its transparency
+//
+// Supported parameters:
+// - bg (color) - background color (default white)
+type FlattenManipulator struct {
+}
+
+// IsOpaque returns true if the image has no transparent or translucent pixels
+func IsOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// FlattenImage composites the image over a solid background, removing its alpha channel.
+// Opaque images are returned as is
+func FlattenImage(img image.Image, bg color.Color) image.Image {
+	if IsOpaque(img) {
+		return img
+	}
+
+	background := color.NRGBA64Model.Convert(bg).(color.NRGBA64)
+	background.A = 0xffff
+
+	bounds := img.Bounds()
+	result := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(result, result.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(result, result.Bounds(), img, bounds.Min, draw.Over)
+
+	return result
+}
+
+// ParseColor parses a hex color value (RGB, RRGGBB or RRGGBBAA) or a color name
+func ParseColor(value string) (color.NRGBA, error) {
+	return parseColor(value)
+}
+
+// Execute runs the flatten manipulator
+func (manipulator *FlattenManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var err error
+	var bg = namedColors["white"]
+	if v, ok := params["bg"]; ok {
+		if bg, err = parseColor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return FlattenImage(img, bg), nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *FlattenManipulator) Schema() Schema {
+	return Schema{
+		Description: "Composites the image over a solid background color",
+		Params: []ParamSchema{
+			Param("bg", ParamColor, "background color").WithDefault("white"),
+		},
+	}
+}
+
+// NewFlattenManipulator returns a new flatten Manipulator
+func NewFlattenManipulator(cfg *config.Config) *FlattenManipulator {
+	return &FlattenManipulator{}
+}
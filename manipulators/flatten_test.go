@@ -0,0 +1,89 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestFlattenImage(t *testing.T) {
+	testImg := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	testImg.Set(0, 0, color.NRGBA{0, 0, 0, 0})
+	testImg.Set(1, 0, color.NRGBA{255, 0, 0, 255})
+
+	result := FlattenImage(testImg, color.White)
+	if !IsOpaque(result) {
+		t.Fatal("expected flattened image to be opaque")
+	}
+
+	if r, g, b, _ := result.At(0, 0).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+		t.Error("expected background color for transparent pixel")
+	}
+
+	if r, g, _, _ := result.At(1, 0).RGBA(); r != 0xffff || g != 0 {
+		t.Error("expected original color for opaque pixel")
+	}
+
+	opaque := newSolidImage(2, 2, color.RGBA{0, 0, 255, 255})
+	if FlattenImage(opaque, color.White) != image.Image(opaque) {
+		t.Error("expected opaque image to be returned as is")
+	}
+}
+
+func TestFlattenManipulator(t *testing.T) {
+	manipulator := NewFlattenManipulator(&config.Config{})
+	testImg := newSolidImage(4, 4, color.RGBA{0, 0, 0, 0})
+
+	var c *fiber.Ctx
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{"default background", map[string]string{}, color.NRGBA{255, 255, 255, 255}, false},
+		{"custom background", map[string]string{"bg": "ff0000"}, color.NRGBA{255, 0, 0, 255}, false},
+		{"invalid background", map[string]string{"bg": "nocolor"}, color.NRGBA{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := color.NRGBAModel.Convert(result.At(1, 1)); got != tt.want {
+				t.Errorf("color = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpacityManipulator(t *testing.T) {
+	manipulator := NewOpacityManipulator(&config.Config{})
+	testImg := newSolidImage(4, 4, color.RGBA{200, 0, 0, 255})
+
+	var c *fiber.Ctx
+	result, err := manipulator.Execute(c, map[string]string{"v": "25"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	got := color.NRGBAModel.Convert(result.At(0, 0)).(color.NRGBA)
+	if got.A != 64 || got.R != 200 {
+		t.Errorf("expected a faded red pixel, got %v", got)
+	}
+
+	for _, params := range []map[string]string{{}, {"v": "150"}, {"v": "abc"}} {
+		if _, err := manipulator.Execute(c, params, testImg); err == nil {
+			t.Errorf("expected an error for %v", params)
+		}
+	}
+}
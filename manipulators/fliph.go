@@ -17,6 +17,13 @@ func (manipulator *FlipHorizontalManipulator) Execute(c *fiber.Ctx, params map[s
 	return transform.FlipH(img), nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *FlipHorizontalManipulator) Schema() Schema {
+	return Schema{
+		Description: "Flips the image horizontally",
+	}
+}
+
 // NewFlipHorizontalManipulator returns a new flip horizontal Manipulator
 func NewFlipHorizontalManipulator(cfg *config.Config) *FlipHorizontalManipulator {
 	return &FlipHorizontalManipulator{}
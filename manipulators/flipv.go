@@ -17,6 +17,13 @@ func (manipulator *FlipVerticalManipulator) Execute(c *fiber.Ctx, params map[str
 	return transform.FlipV(img), nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *FlipVerticalManipulator) Schema() Schema {
+	return Schema{
+		Description: "Flips the image vertically",
+	}
+}
+
 // NewFlipVerticalManipulator returns a new flip vertical Manipulator
 func NewFlipVerticalManipulator(cfg *config.Config) *FlipVerticalManipulator {
 	return &FlipVerticalManipulator{}
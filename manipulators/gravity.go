@@ -0,0 +1,56 @@
+package manipulators
+
+import (
+	"image"
+)
+
+// gravityRegistry maps anchor names to functions that position a box of the given
+// size inside the base rectangle. The margin is applied inwards from the anchored
+// edges (for center anchors it shifts the box right/down)
+var gravityRegistry = map[string]func(b image.Rectangle, size image.Point, margin image.Point) image.Point{
+	"topleft": func(b image.Rectangle, size image.Point, margin image.Point) image.Point {
+		return image.Pt(b.Min.X+margin.X, b.Min.Y+margin.Y)
+	},
+	"topcenter": func(b image.Rectangle, size image.Point, margin image.Point) image.Point {
+		return image.Pt(b.Min.X+(b.Dx()-size.X)/2+margin.X, b.Min.Y+margin.Y)
+	},
+	"topright": func(b image.Rectangle, size image.Point, margin image.Point) image.Point {
+		return image.Pt(b.Max.X-size.X-margin.X, b.Min.Y+margin.Y)
+	},
+	"centerleft": func(b image.Rectangle, size image.Point, margin image.Point) image.Point {
+		return image.Pt(b.Min.X+margin.X, b.Min.Y+(b.Dy()-size.Y)/2+margin.Y)
+	},
+	"center": func(b image.Rectangle, size image.Point, margin image.Point) image.Point {
+		return image.Pt(b.Min.X+(b.Dx()-size.X)/2+margin.X, b.Min.Y+(b.Dy()-size.Y)/2+margin.Y)
+	},
+	"centerright": func(b image.Rectangle, size image.Point, margin image.Point) image.Point {
+		return image.Pt(b.Max.X-size.X-margin.X, b.Min.Y+(b.Dy()-size.Y)/2+margin.Y)
+	},
+	"bottomleft": func(b image.Rectangle, size image.Point, margin image.Point) image.Point {
+		return image.Pt(b.Min.X+margin.X, b.Max.Y-size.Y-margin.Y)
+	},
+	"bottomcenter": func(b image.Rectangle, size image.Point, margin image.Point) image.Point {
+		return image.Pt(b.Min.X+(b.Dx()-size.X)/2+margin.X, b.Max.Y-size.Y-margin.Y)
+	},
+	"bottomright": func(b image.Rectangle, size image.Point, margin image.Point) image.Point {
+		return image.Pt(b.Max.X-size.X-margin.X, b.Max.Y-size.Y-margin.Y)
+	},
+}
+
+// isValidGravity returns true if the name is a registered anchor
+func isValidGravity(name string) bool {
+	_, ok := gravityRegistry[name]
+	return ok
+}
+
+// gravityRect returns the rectangle of the specified size positioned inside b by the named anchor.
+// Unknown anchors fall back to "topleft"
+func gravityRect(name string, b image.Rectangle, size image.Point, margin image.Point) image.Rectangle {
+	f, ok := gravityRegistry[name]
+	if !ok {
+		f = gravityRegistry["topleft"]
+	}
+
+	min := f(b, size, margin)
+	return image.Rectangle{Min: min, Max: min.Add(size)}
+}
@@ -0,0 +1,69 @@
+package manipulators
+
+import (
+	"image"
+	"math"
+)
+
+// luminance returns the Rec. 601 luma of a color with 0-255 channels
+func luminance(r, g, b float64) float64 {
+	return 0.299*r + 0.587*g + 0.114*b
+}
+
+// clampChannel rounds and clamps a channel value to 0-255
+func clampChannel(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+// luminanceHistogram builds a histogram of the luma of the visible pixels in the region
+func luminanceHistogram(img *image.NRGBA, region image.Rectangle) ([256]int, int) {
+	var hist [256]int
+	var total int
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			px := img.Pix[i : i+4 : i+4]
+			if px[3] == 0 {
+				continue
+			}
+			hist[clampChannel(luminance(float64(px[0]), float64(px[1]), float64(px[2])))]++
+			total++
+		}
+	}
+	return hist, total
+}
+
+// histogramPercentile returns the lowest value under which at least the given fraction of the samples fall
+func histogramPercentile(hist [256]int, total int, fraction float64) int {
+	target := int(math.Ceil(float64(total) * fraction))
+	var count int
+	for v, n := range hist {
+		count += n
+		if count >= target && count > 0 {
+			return v
+		}
+	}
+	return 255
+}
+
+// remapLuminance scales the channels of every pixel so that its luma is mapped through f, blending the
+// result with the original pixel by strength (0-1)
+func remapLuminance(img *image.NRGBA, strength float64, f func(x, y int, luma float64) float64) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			px := img.Pix[i : i+3 : i+3]
+
+			r, g, bl := float64(px[0]), float64(px[1]), float64(px[2])
+			luma := luminance(r, g, bl)
+			target := f(x, y, luma)
+
+			// Shift the channels by the luma difference to keep the hue and saturation of the pixel
+			delta := (target - luma) * strength
+			px[0] = clampChannel(r + delta)
+			px[1] = clampChannel(g + delta)
+			px[2] = clampChannel(bl + delta)
+		}
+	}
+}
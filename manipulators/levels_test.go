@@ -0,0 +1,135 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newGradientImage returns a horizontal gray gradient between the given luma values with a color tint
+func newGradientImage(width, height int, from, to uint8, tint [3]int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := int(from) + (int(to)-int(from))*x/(width-1)
+			img.Set(x, y, color.NRGBA{
+				uint8(clampInt(v+tint[0], 0, 255)),
+				uint8(clampInt(v+tint[1], 0, 255)),
+				uint8(clampInt(v+tint[2], 0, 255)),
+				255,
+			})
+		}
+	}
+	return img
+}
+
+// lumaRange returns the darkest and brightest luma of the image
+func lumaRange(img image.Image) (uint8, uint8) {
+	result := toNRGBA(img)
+	hist, total := luminanceHistogram(result, result.Bounds())
+	return uint8(histogramPercentile(hist, total, 0)), uint8(histogramPercentile(hist, total, 1))
+}
+
+func TestAutoLevelsManipulator(t *testing.T) {
+	manipulator := NewAutoLevelsManipulator(&config.Config{})
+	testImg := newGradientImage(64, 4, 80, 160, [3]int{})
+
+	var c *fiber.Ctx
+	tests := []struct {
+		name     string
+		params   map[string]string
+		wantLow  uint8
+		wantHigh uint8
+		wantErr  bool
+	}{
+		{"full strength", map[string]string{"clip": "0"}, 0, 255, false},
+		{"no strength", map[string]string{"s": "0"}, 80, 160, false},
+		{"half strength", map[string]string{"s": "50", "clip": "0"}, 40, 208, false},
+		{"invalid strength", map[string]string{"s": "150"}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			low, high := lumaRange(result)
+			if low != tt.wantLow || high != tt.wantHigh {
+				t.Errorf("luma range = %d-%d, want %d-%d", low, high, tt.wantLow, tt.wantHigh)
+			}
+		})
+	}
+}
+
+func TestEqualizeManipulator(t *testing.T) {
+	manipulator := NewEqualizeManipulator(&config.Config{})
+	testImg := newGradientImage(64, 64, 100, 140, [3]int{})
+
+	var c *fiber.Ctx
+	tests := []struct {
+		name    string
+		params  map[string]string
+		wantErr bool
+	}{
+		{"global", map[string]string{}, false},
+		{"clahe", map[string]string{"mode": "clahe", "tiles": "4", "limit": "4"}, false},
+		{"unknown mode", map[string]string{"mode": "local"}, true},
+		{"invalid tiles", map[string]string{"mode": "clahe", "tiles": "0"}, true},
+		{"invalid limit", map[string]string{"mode": "clahe", "limit": "0.5"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if result.Bounds() != testImg.Bounds() {
+				t.Errorf("bounds = %v, want %v", result.Bounds(), testImg.Bounds())
+			}
+
+			low, high := lumaRange(result)
+			if int(high)-int(low) <= 40 {
+				t.Errorf("expected the luma range to be widened, got %d-%d", low, high)
+			}
+		})
+	}
+}
+
+func TestAutoWhiteBalanceManipulator(t *testing.T) {
+	manipulator := NewAutoWhiteBalanceManipulator(&config.Config{})
+	// A gray gradient with a blue cast
+	testImg := newGradientImage(64, 4, 40, 200, [3]int{-20, 0, 30})
+
+	var c *fiber.Ctx
+	for _, mode := range []string{"grayworld", "whitepatch"} {
+		t.Run(mode, func(t *testing.T) {
+			result, err := manipulator.Execute(c, map[string]string{"mode": mode}, testImg)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			before := color.NRGBAModel.Convert(testImg.At(32, 0)).(color.NRGBA)
+			after := color.NRGBAModel.Convert(result.At(32, 0)).(color.NRGBA)
+			if int(after.B)-int(after.R) >= int(before.B)-int(before.R) {
+				t.Errorf("expected the blue cast to be reduced, got %v from %v", after, before)
+			}
+		})
+	}
+
+	if _, err := manipulator.Execute(c, map[string]string{"mode": "auto"}, testImg); err == nil {
+		t.Errorf("expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,295 @@
+package manipulators
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	lutInterpolationTrilinear   = "trilinear"
+	lutInterpolationTetrahedral = "tetrahedral"
+)
+
+// cubeLUT is a 3D color lookup table as defined by the .cube file format
+type cubeLUT struct {
+	Size      int
+	DomainMin [3]float64
+	DomainMax [3]float64
+	// Table holds Size^3 output colors where the red index changes fastest
+	Table [][3]float64
+}
+
+// parseCubeLUT parses a 3D LUT in the .cube file format
+func parseCubeLUT(r io.Reader) (*cubeLUT, error) {
+	lut := &cubeLUT{DomainMax: [3]float64{1, 1, 1}}
+
+	parseTriplet := func(fields []string) ([3]float64, error) {
+		var triplet [3]float64
+		if len(fields) != 3 {
+			return triplet, fmt.Errorf("expected 3 values, got %d", len(fields))
+		}
+		for i, f := range fields {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return triplet, fmt.Errorf("invalid value '%s'", f)
+			}
+			triplet[i] = v
+		}
+		return triplet, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		var err error
+		switch fields[0] {
+		case "TITLE":
+			continue
+		case "LUT_1D_SIZE":
+			return nil, fmt.Errorf("1D LUTs are not supported")
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: invalid LUT_3D_SIZE", lineNumber)
+			}
+			if lut.Size, err = strconv.Atoi(fields[1]); err != nil || lut.Size < 2 || lut.Size > 256 {
+				return nil, fmt.Errorf("line %d: LUT_3D_SIZE must be between 2 and 256", lineNumber)
+			}
+			lut.Table = make([][3]float64, 0, lut.Size*lut.Size*lut.Size)
+		case "DOMAIN_MIN":
+			if lut.DomainMin, err = parseTriplet(fields[1:]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid DOMAIN_MIN: %v", lineNumber, err)
+			}
+		case "DOMAIN_MAX":
+			if lut.DomainMax, err = parseTriplet(fields[1:]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid DOMAIN_MAX: %v", lineNumber, err)
+			}
+		default:
+			if lut.Size == 0 {
+				return nil, fmt.Errorf("line %d: LUT_3D_SIZE must be declared before the table data", lineNumber)
+			}
+
+			var value [3]float64
+			if value, err = parseTriplet(fields); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+
+			if len(lut.Table) == cap(lut.Table) {
+				return nil, fmt.Errorf("line %d: too many table entries", lineNumber)
+			}
+			lut.Table = append(lut.Table, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if lut.Size == 0 || len(lut.Table) != lut.Size*lut.Size*lut.Size {
+		return nil, fmt.Errorf("expected %d table entries, got %d", lut.Size*lut.Size*lut.Size, len(lut.Table))
+	}
+
+	for i := 0; i < 3; i++ {
+		if lut.DomainMax[i] <= lut.DomainMin[i] {
+			return nil, fmt.Errorf("DOMAIN_MAX must be greater than DOMAIN_MIN")
+		}
+	}
+
+	return lut, nil
+}
+
+// loadCubeLUT loads a 3D LUT from a .cube file
+func loadCubeLUT(filename string) (*cubeLUT, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseCubeLUT(f)
+}
+
+// at returns the table entry at the specified red, green and blue indexes
+func (lut *cubeLUT) at(r, g, b int) [3]float64 {
+	return lut.Table[r+g*lut.Size+b*lut.Size*lut.Size]
+}
+
+// lookup maps a color with channels in 0-1 through the LUT
+func (lut *cubeLUT) lookup(in [3]float64, interpolation string) [3]float64 {
+	var index [3]int
+	var frac [3]float64
+	max := float64(lut.Size - 1)
+
+	for i := 0; i < 3; i++ {
+		v := (in[i] - lut.DomainMin[i]) / (lut.DomainMax[i] - lut.DomainMin[i]) * max
+		v = math.Max(0, math.Min(max, v))
+		index[i] = int(math.Min(v, max-1))
+		frac[i] = v - float64(index[i])
+	}
+
+	r, g, b := index[0], index[1], index[2]
+	fr, fg, fb := frac[0], frac[1], frac[2]
+
+	c000 := lut.at(r, g, b)
+	c111 := lut.at(r+1, g+1, b+1)
+
+	var out [3]float64
+	if interpolation == lutInterpolationTetrahedral {
+		// Split the cube into 6 tetrahedra and interpolate inside the one containing the color
+		var w0, w1, w2, w3 float64
+		var c1, c2 [3]float64
+		switch {
+		case fr > fg && fg > fb:
+			c1, c2 = lut.at(r+1, g, b), lut.at(r+1, g+1, b)
+			w0, w1, w2, w3 = 1-fr, fr-fg, fg-fb, fb
+		case fr > fb && fb >= fg:
+			c1, c2 = lut.at(r+1, g, b), lut.at(r+1, g, b+1)
+			w0, w1, w2, w3 = 1-fr, fr-fb, fb-fg, fg
+		case fb >= fr && fr > fg:
+			c1, c2 = lut.at(r, g, b+1), lut.at(r+1, g, b+1)
+			w0, w1, w2, w3 = 1-fb, fb-fr, fr-fg, fg
+		case fb > fg && fg >= fr:
+			c1, c2 = lut.at(r, g, b+1), lut.at(r, g+1, b+1)
+			w0, w1, w2, w3 = 1-fb, fb-fg, fg-fr, fr
+		case fg >= fb && fb > fr:
+			c1, c2 = lut.at(r, g+1, b), lut.at(r, g+1, b+1)
+			w0, w1, w2, w3 = 1-fg, fg-fb, fb-fr, fr
+		default:
+			c1, c2 = lut.at(r, g+1, b), lut.at(r+1, g+1, b)
+			w0, w1, w2, w3 = 1-fg, fg-fr, fr-fb, fb
+		}
+
+		for i := 0; i < 3; i++ {
+			out[i] = w0*c000[i] + w1*c1[i] + w2*c2[i] + w3*c111[i]
+		}
+		return out
+	}
+
+	c100 := lut.at(r+1, g, b)
+	c010 := lut.at(r, g+1, b)
+	c110 := lut.at(r+1, g+1, b)
+	c001 := lut.at(r, g, b+1)
+	c101 := lut.at(r+1, g, b+1)
+	c011 := lut.at(r, g+1, b+1)
+
+	for i := 0; i < 3; i++ {
+		c00 := c000[i]*(1-fr) + c100[i]*fr
+		c10 := c010[i]*(1-fr) + c110[i]*fr
+		c01 := c001[i]*(1-fr) + c101[i]*fr
+		c11 := c011[i]*(1-fr) + c111[i]*fr
+		c0 := c00*(1-fg) + c10*fg
+		c1 := c01*(1-fg) + c11*fg
+		out[i] = c0*(1-fb) + c1*fb
+	}
+
+	return out
+}
+
+// LUTManipulator applies a named 3D LUT declared in the "luts" section of the config
+//
+// Supported parameters:
+// - name (string) - name of the LUT as declared in the config
+// - interp (string) - trilinear (default) or tetrahedral interpolation
+// - i (float 0-100) - intensity in percentages with which the graded image is blended over the original (default 100)
+type LUTManipulator struct {
+	LUTs map[string]*cubeLUT
+}
+
+// Execute runs the LUT manipulator
+func (manipulator *LUTManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	name, ok := params["name"]
+	if !ok {
+		return nil, fmt.Errorf("lut requires a name parameter")
+	}
+
+	lut, ok := manipulator.LUTs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown LUT '%s'", name)
+	}
+
+	var interpolation = lutInterpolationTrilinear
+	if v, ok := params["interp"]; ok {
+		if v != lutInterpolationTrilinear && v != lutInterpolationTetrahedral {
+			return nil, fmt.Errorf("unknown LUT interpolation '%s'", v)
+		}
+		interpolation = v
+	}
+
+	intensity, err := parsePercentageParam(params, "i", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	result := toNRGBA(img)
+	b := result.Bounds()
+
+	// Cache lookups as photos tend to repeat colors
+	cache := map[[3]uint8][3]uint8{}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := result.PixOffset(x, y)
+			px := result.Pix[i : i+3 : i+3]
+			key := [3]uint8{px[0], px[1], px[2]}
+
+			mapped, ok := cache[key]
+			if !ok {
+				out := lut.lookup([3]float64{float64(px[0]) / 255, float64(px[1]) / 255, float64(px[2]) / 255}, interpolation)
+				for ch := 0; ch < 3; ch++ {
+					v := float64(px[ch])*(1-intensity) + out[ch]*255*intensity
+					mapped[ch] = uint8(math.Max(0, math.Min(255, math.Round(v))))
+				}
+				cache[key] = mapped
+			}
+
+			copy(px, mapped[:])
+		}
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *LUTManipulator) Schema() Schema {
+	return Schema{
+		Description: "Applies a 3D LUT declared in the config",
+		Params: []ParamSchema{
+			EnumParam("name", "LUT name", slices.Sorted(maps.Keys(manipulator.LUTs))...).AsRequired(),
+			EnumParam("interp", "interpolation", "trilinear", "tetrahedral").WithDefault("trilinear"),
+			Param("i", ParamFloat, "intensity in percentages").Range(0, 100).WithDefault("100"),
+		},
+	}
+}
+
+// NewLUTManipulator returns a new LUT Manipulator, loading the LUTs declared in the config
+func NewLUTManipulator(cfg *config.Config) *LUTManipulator {
+	luts := map[string]*cubeLUT{}
+	for _, lutCfg := range cfg.LUTs {
+		lut, err := loadCubeLUT(lutCfg.File)
+		if err != nil {
+			log.Printf("Failed to load LUT '%s' from '%s': %v", lutCfg.Name, lutCfg.File, err)
+			continue
+		}
+		luts[lutCfg.Name] = lut
+	}
+
+	return &LUTManipulator{LUTs: luts}
+}
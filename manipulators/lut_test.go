@@ -0,0 +1,116 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// buildCubeLUT returns a .cube file content of the given size with every entry mapped by f
+func buildCubeLUT(size int, f func(r, g, b float64) (float64, float64, float64)) string {
+	var sb strings.Builder
+	sb.WriteString("# Test LUT\nTITLE \"test\"\n")
+	fmt.Fprintf(&sb, "LUT_3D_SIZE %d\n", size)
+	max := float64(size - 1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				or, og, ob := f(float64(r)/max, float64(g)/max, float64(b)/max)
+				fmt.Fprintf(&sb, "%f %f %f\n", or, og, ob)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func TestParseCubeLUT(t *testing.T) {
+	identity := buildCubeLUT(2, func(r, g, b float64) (float64, float64, float64) { return r, g, b })
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"identity", identity, false},
+		{"1D LUT", "LUT_1D_SIZE 2\n0 0 0\n1 1 1\n", true},
+		{"missing size", "0 0 0\n", true},
+		{"missing entries", "LUT_3D_SIZE 2\n0 0 0\n", true},
+		{"invalid value", strings.Replace(identity, "1.000000 1.000000 1.000000", "1 x 1", 1), true},
+		{"invalid domain", "DOMAIN_MIN 1 1 1\nDOMAIN_MAX 0 0 0\n" + identity, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCubeLUT(strings.NewReader(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCubeLUT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLUTManipulator(t *testing.T) {
+	dir := t.TempDir()
+	invertFile := filepath.Join(dir, "invert.cube")
+	invert := buildCubeLUT(3, func(r, g, b float64) (float64, float64, float64) { return 1 - r, 1 - g, 1 - b })
+	if err := os.WriteFile(invertFile, []byte(invert), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		LUTs: []config.LUTConfig{
+			{Name: "invert", File: invertFile},
+			{Name: "missing", File: filepath.Join(dir, "missing.cube")},
+		},
+	}
+	manipulator := NewLUTManipulator(cfg)
+
+	if _, ok := manipulator.LUTs["missing"]; ok {
+		t.Errorf("expected a LUT that failed to load to be skipped")
+	}
+
+	testImg := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	testImg.Set(0, 0, color.NRGBA{200, 100, 40, 255})
+
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    [3]uint8
+		wantErr bool
+	}{
+		{"trilinear", map[string]string{"name": "invert"}, [3]uint8{55, 155, 215}, false},
+		{"tetrahedral", map[string]string{"name": "invert", "interp": "tetrahedral"}, [3]uint8{55, 155, 215}, false},
+		{"half intensity", map[string]string{"name": "invert", "i": "50"}, [3]uint8{128, 128, 128}, false},
+		{"no intensity", map[string]string{"name": "invert", "i": "0"}, [3]uint8{200, 100, 40}, false},
+		{"missing name", map[string]string{}, [3]uint8{}, true},
+		{"unknown name", map[string]string{"name": "missing"}, [3]uint8{}, true},
+		{"invalid interpolation", map[string]string{"name": "invert", "interp": "cubic"}, [3]uint8{}, true},
+	}
+
+	var c *fiber.Ctx
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := color.NRGBAModel.Convert(result.At(0, 0)).(color.NRGBA)
+			for i, v := range [3]uint8{got.R, got.G, got.B} {
+				if diff := int(v) - int(tt.want[i]); diff < -1 || diff > 1 {
+					t.Errorf("channel %d = %d, want %d", i, v, tt.want[i])
+				}
+			}
+		})
+	}
+}
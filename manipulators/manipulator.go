@@ -10,6 +10,8 @@ import (
 // Manipulator interface
 type Manipulator interface {
 	Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error)
+	// Schema describes the parameters accepted by Execute, used to validate requests and by the /manipulators endpoint
+	Schema() Schema
 }
 
 var manipulatorsRegistry map[string]Manipulator
@@ -29,18 +31,41 @@ func GetManipulatorByName(name string) Manipulator {
 func InitManipulators(cfg *config.Config) {
 	manipulatorsRegistry = map[string]Manipulator{
 		// transformation manipulators
-		"output":     NewOutputManipulator(cfg),
-		"rotate":     NewRotateManipulator(cfg),
-		"flipv":      NewFlipVerticalManipulator(cfg),
-		"fliph":      NewFlipHorizontalManipulator(cfg),
-		"resize":     NewResizeManipulator(cfg),
-		"fit":        NewFitManipulator(cfg),
-		"crop":       NewCropManipulator(cfg),
-		"shearv":     NewShearVerticalManipulator(cfg),
-		"shearh":     NewShearHorizontalManipulator(cfg),
-		"facecrop":   NewFaceCropManipulator(cfg),
-		"paste":      NewPasteManipulator(cfg),
-		"contrast":   NewContrastManipulator(cfg),
-		"brightness": NewBrightnessManipulator(cfg),
+		"output":       NewOutputManipulator(cfg),
+		"rotate":       NewRotateManipulator(cfg),
+		"flipv":        NewFlipVerticalManipulator(cfg),
+		"fliph":        NewFlipHorizontalManipulator(cfg),
+		"resize":       NewResizeManipulator(cfg),
+		"fit":          NewFitManipulator(cfg),
+		"crop":         NewCropManipulator(cfg),
+		"shearv":       NewShearVerticalManipulator(cfg),
+		"shearh":       NewShearHorizontalManipulator(cfg),
+		"affine":       NewAffineManipulator(cfg),
+		"perspective":  NewPerspectiveManipulator(cfg),
+		"facecrop":     NewFaceCropManipulator(cfg),
+		"paste":        NewPasteManipulator(cfg),
+		"contrast":     NewContrastManipulator(cfg),
+		"brightness":   NewBrightnessManipulator(cfg),
+		"denoise":      NewDenoiseManipulator(cfg),
+		"deblock":      NewDeblockManipulator(cfg),
+		"effect":       NewEffectManipulator(cfg),
+		"autolevels":   NewAutoLevelsManipulator(cfg),
+		"equalize":     NewEqualizeManipulator(cfg),
+		"autowb":       NewAutoWhiteBalanceManipulator(cfg),
+		"round":        NewRoundManipulator(cfg),
+		"circle":       NewCircleManipulator(cfg),
+		"flatten":      NewFlattenManipulator(cfg),
+		"opacity":      NewOpacityManipulator(cfg),
+		"border":       NewBorderManipulator(cfg),
+		"shadow":       NewShadowManipulator(cfg),
+		"vignette":     NewVignetteManipulator(cfg),
+		"pad":          NewPadManipulator(cfg),
+		"extend":       NewExtendManipulator(cfg),
+		"trim":         NewTrimManipulator(cfg),
+		"redact":       NewRedactManipulator(cfg),
+		"chromakey":    NewChromaKeyManipulator(cfg),
+		"replacecolor": NewReplaceColorManipulator(cfg),
+		"lut":          NewLUTManipulator(cfg),
+		"quantize":     NewQuantizeManipulator(cfg),
 	}
 }
@@ -0,0 +1,97 @@
+package manipulators
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// cornerRadii holds the radius of each corner of a rounded rectangle
+type cornerRadii struct {
+	TopLeft     float64
+	TopRight    float64
+	BottomRight float64
+	BottomLeft  float64
+}
+
+// clamp limits every radius to half of the smaller side of a w x h rectangle
+func (r cornerRadii) clamp(w, h float64) cornerRadii {
+	max := math.Min(w, h) / 2
+	return cornerRadii{
+		TopLeft:     math.Max(0, math.Min(r.TopLeft, max)),
+		TopRight:    math.Max(0, math.Min(r.TopRight, max)),
+		BottomRight: math.Max(0, math.Min(r.BottomRight, max)),
+		BottomLeft:  math.Max(0, math.Min(r.BottomLeft, max)),
+	}
+}
+
+// toNRGBA returns a copy of the image as NRGBA with its origin at (0, 0)
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// edgeCoverage converts a signed distance from an edge (positive inside) into an anti-aliased coverage value
+func edgeCoverage(distance float64) float64 {
+	return math.Max(0, math.Min(1, distance+0.5))
+}
+
+// roundedRectCoverage returns how much of the pixel centered at (px, py) is covered by a w x h rounded rectangle
+// positioned at the origin
+func roundedRectCoverage(px, py, w, h float64, r cornerRadii) float64 {
+	if px < 0 || py < 0 || px > w || py > h {
+		return 0
+	}
+
+	var cx, cy, radius float64
+	switch {
+	case px < r.TopLeft && py < r.TopLeft:
+		cx, cy, radius = r.TopLeft, r.TopLeft, r.TopLeft
+	case px > w-r.TopRight && py < r.TopRight:
+		cx, cy, radius = w-r.TopRight, r.TopRight, r.TopRight
+	case px > w-r.BottomRight && py > h-r.BottomRight:
+		cx, cy, radius = w-r.BottomRight, h-r.BottomRight, r.BottomRight
+	case px < r.BottomLeft && py > h-r.BottomLeft:
+		cx, cy, radius = r.BottomLeft, h-r.BottomLeft, r.BottomLeft
+	default:
+		// Straight edges
+		return edgeCoverage(math.Min(math.Min(px, w-px), math.Min(py, h-py)))
+	}
+
+	return edgeCoverage(radius - math.Hypot(px-cx, py-cy))
+}
+
+// ellipseCoverage returns how much of the pixel centered at (px, py) is covered by an ellipse
+func ellipseCoverage(px, py, cx, cy, rx, ry float64) float64 {
+	if rx <= 0 || ry <= 0 {
+		return 0
+	}
+
+	dx := (px - cx) / rx
+	dy := (py - cy) / ry
+	d := math.Hypot(dx, dy)
+	if d == 0 {
+		return 1
+	}
+
+	// Approximate the distance from the edge in pixels along the ray from the center
+	return edgeCoverage((1 - d) * math.Hypot(dx*rx, dy*ry) / d)
+}
+
+// applyCoverage multiplies the alpha channel of every pixel by the coverage returned by f
+func applyCoverage(img *image.NRGBA, f func(px, py float64) float64) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			coverage := f(float64(x-b.Min.X)+0.5, float64(y-b.Min.Y)+0.5)
+			if coverage >= 1 {
+				continue
+			}
+
+			i := img.PixOffset(x, y)
+			img.Pix[i+3] = uint8(math.Round(float64(img.Pix[i+3]) * coverage))
+		}
+	}
+}
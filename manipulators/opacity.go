@@ -0,0 +1,51 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// OpacityManipulator fades the image by scaling its alpha channel
+//
+// Supported parameters:
+// - v (float 0-100) - opacity in percentages. 0 is fully transparent, 100 keeps the image as is
+type OpacityManipulator struct {
+}
+
+// Execute runs the opacity manipulator
+func (manipulator *OpacityManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	if _, ok := params["v"]; !ok {
+		return nil, fmt.Errorf("opacity requires a value (v) parameter")
+	}
+
+	opacity, err := parsePercentageParam(params, "v", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	result := toNRGBA(img)
+	for i := 3; i < len(result.Pix); i += 4 {
+		result.Pix[i] = uint8(math.Round(float64(result.Pix[i]) * opacity))
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *OpacityManipulator) Schema() Schema {
+	return Schema{
+		Description: "Fades the image",
+		Params: []ParamSchema{
+			Param("v", ParamFloat, "opacity in percentages").Range(0, 100).AsRequired(),
+		},
+	}
+}
+
+// NewOpacityManipulator returns a new opacity Manipulator
+func NewOpacityManipulator(cfg *config.Config) *OpacityManipulator {
+	return &OpacityManipulator{}
+}
@@ -3,6 +3,8 @@ package manipulators
 import (
 	"fmt"
 	"image"
+	"maps"
+	"slices"
 
 	"github.com/erans/thumbla/config"
 	"github.com/erans/thumbla/middleware"
@@ -70,6 +72,20 @@ func (manipulator *OutputManipulator) Execute(c *fiber.Ctx, params map[string]st
 	return img, nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *OutputManipulator) Schema() Schema {
+	return Schema{
+		Description: "Sets the output format of the image",
+		Params: []ParamSchema{
+			EnumParam("f", "output format", slices.Sorted(maps.Keys(formatContentTypeMapping))...),
+			Param("q", ParamInt, "jpeg and webp quality").Range(1, 100).WithDefault("90"),
+			Param("lossless", ParamBool, "webp lossless compression"),
+			Param("exact", ParamBool, "webp keeps the color values of transparent pixels"),
+			EnumParam("e", "jpeg encoder", "jpeg", "guetzli").WithDefault("jpeg"),
+		},
+	}
+}
+
 // NewOutputManipulator returns a new Output Manipulator
 func NewOutputManipulator(cfg *config.Config) *OutputManipulator {
 	return &OutputManipulator{}
@@ -0,0 +1,90 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PadManipulator adds padding around the image
+//
+// Supported parameters:
+// - t, r, b, l - top, right, bottom and left padding in pixels or as a percentage (i.e. 10%) of the image height (t, b) or width (l, r)
+// - p - padding for all sides that were not specified explicitly
+// - bg (color) - fill color as a hex value or a color name (default transparent)
+// - mode (string) - fill mode: color (default), edge - repeat edge pixels, mirror - mirror the image
+type PadManipulator struct {
+	Cfg *config.Config
+}
+
+// Execute runs the pad manipulator and adds padding around the image
+func (manipulator *PadManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	mode, bg, err := parseCanvasFill(params)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	sides := []struct {
+		name  string
+		total int
+		value int
+	}{
+		{"t", b.Dy(), 0},
+		{"r", b.Dx(), 0},
+		{"b", b.Dy(), 0},
+		{"l", b.Dx(), 0},
+	}
+
+	for i, side := range sides {
+		v, ok := params[side.name]
+		if !ok {
+			v, ok = params["p"]
+		}
+
+		if ok {
+			if sides[i].value, err = parseLength(v, side.total); err != nil {
+				return nil, err
+			}
+
+			if sides[i].value < 0 {
+				return nil, fmt.Errorf("padding (%s) must not be negative", side.name)
+			}
+		}
+	}
+
+	top, right, bottom, left := sides[0].value, sides[1].value, sides[2].value, sides[3].value
+	if top == 0 && right == 0 && bottom == 0 && left == 0 {
+		return img, nil
+	}
+
+	size := image.Pt(b.Dx()+left+right, b.Dy()+top+bottom)
+	if err := checkCanvasSize(manipulator.Cfg, size); err != nil {
+		return nil, err
+	}
+
+	return extendCanvas(img, size, image.Pt(left, top), mode, bg), nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *PadManipulator) Schema() Schema {
+	return Schema{
+		Description: "Adds padding around the image",
+		Params: []ParamSchema{
+			Param("t", ParamLength, "top padding").Range(0, 20000),
+			Param("r", ParamLength, "right padding").Range(0, 20000),
+			Param("b", ParamLength, "bottom padding").Range(0, 20000),
+			Param("l", ParamLength, "left padding").Range(0, 20000),
+			Param("p", ParamLength, "padding of the sides not given explicitly").Range(0, 20000),
+			Param("bg", ParamColor, "fill color").WithDefault("transparent"),
+			canvasFillModeParam(),
+		},
+	}
+}
+
+// NewPadManipulator returns a new pad Manipulator
+func NewPadManipulator(cfg *config.Config) *PadManipulator {
+	return &PadManipulator{Cfg: cfg}
+}
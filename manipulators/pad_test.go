@@ -0,0 +1,168 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPadManipulator(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewPadManipulator(cfg)
+	testImg := newSolidImage(20, 10, color.RGBA{255, 0, 0, 255})
+
+	tests := []struct {
+		name     string
+		params   map[string]string
+		expected image.Rectangle
+		fillAt   image.Point
+		fill     color.NRGBA
+	}{
+		{
+			name:     "same padding on all sides",
+			params:   map[string]string{"p": "5", "bg": "ffffff"},
+			expected: image.Rect(0, 0, 30, 20),
+			fillAt:   image.Pt(0, 0),
+			fill:     color.NRGBA{255, 255, 255, 255},
+		},
+		{
+			name:     "per side padding with transparent background",
+			params:   map[string]string{"t": "1", "r": "2", "b": "3", "l": "4"},
+			expected: image.Rect(0, 0, 26, 14),
+			fillAt:   image.Pt(0, 0),
+			fill:     color.NRGBA{0, 0, 0, 0},
+		},
+		{
+			name:     "percentage padding",
+			params:   map[string]string{"l": "50%", "t": "50%", "bg": "black"},
+			expected: image.Rect(0, 0, 30, 15),
+			fillAt:   image.Pt(9, 4),
+			fill:     color.NRGBA{0, 0, 0, 255},
+		},
+		{
+			name:     "edge fill mode",
+			params:   map[string]string{"p": "3", "mode": "edge"},
+			expected: image.Rect(0, 0, 26, 16),
+			fillAt:   image.Pt(0, 0),
+			fill:     color.NRGBA{255, 0, 0, 255},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *fiber.Ctx
+
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if result.Bounds() != tt.expected {
+				t.Errorf("Execute() bounds = %v, expected %v", result.Bounds(), tt.expected)
+			}
+
+			if got := color.NRGBAModel.Convert(result.At(tt.fillAt.X, tt.fillAt.Y)).(color.NRGBA); got != tt.fill {
+				t.Errorf("fill at %v = %v, expected %v", tt.fillAt, got, tt.fill)
+			}
+		})
+	}
+
+	if _, err := manipulator.Execute(nil, map[string]string{"p": "abc"}, testImg); err == nil {
+		t.Error("Expected error for invalid padding")
+	}
+
+	for _, params := range []map[string]string{{"t": "100000000"}, {"p": "1000000%"}} {
+		if _, err := manipulator.Execute(nil, params, testImg); err == nil {
+			t.Errorf("Expected error for padding %v larger than the max image dimension", params)
+		}
+	}
+}
+
+func TestExtendManipulator(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewExtendManipulator(cfg)
+
+	// 2x1 image: red pixel on the left, blue pixel on the right
+	testImg := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	testImg.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	testImg.Set(1, 0, color.NRGBA{0, 0, 255, 255})
+
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+
+	tests := []struct {
+		name     string
+		params   map[string]string
+		expected image.Rectangle
+		pixels   map[image.Point]color.NRGBA
+	}{
+		{
+			name:     "centered on white",
+			params:   map[string]string{"w": "6", "h": "3", "bg": "fff"},
+			expected: image.Rect(0, 0, 6, 3),
+			pixels: map[image.Point]color.NRGBA{
+				{0, 0}: {255, 255, 255, 255},
+				{2, 1}: red,
+				{3, 1}: blue,
+			},
+		},
+		{
+			name:     "bottom right gravity",
+			params:   map[string]string{"w": "4", "h": "2", "g": "bottomright"},
+			expected: image.Rect(0, 0, 4, 2),
+			pixels: map[image.Point]color.NRGBA{
+				{2, 1}: red,
+				{3, 1}: blue,
+				{0, 0}: {0, 0, 0, 0},
+			},
+		},
+		{
+			name:     "mirror mode",
+			params:   map[string]string{"w": "6", "g": "topleft", "mode": "mirror"},
+			expected: image.Rect(0, 0, 6, 1),
+			pixels: map[image.Point]color.NRGBA{
+				{2, 0}: blue,
+				{3, 0}: red,
+				{4, 0}: red,
+			},
+		},
+		{
+			name:     "smaller size keeps image size",
+			params:   map[string]string{"w": "1", "h": "1"},
+			expected: image.Rect(0, 0, 2, 1),
+			pixels:   map[image.Point]color.NRGBA{{0, 0}: red},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *fiber.Ctx
+
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if result.Bounds() != tt.expected {
+				t.Errorf("Execute() bounds = %v, expected %v", result.Bounds(), tt.expected)
+			}
+
+			for p, expected := range tt.pixels {
+				if got := color.NRGBAModel.Convert(result.At(p.X, p.Y)).(color.NRGBA); got != expected {
+					t.Errorf("pixel at %v = %v, expected %v", p, got, expected)
+				}
+			}
+		})
+	}
+
+	if _, err := manipulator.Execute(nil, map[string]string{"w": "10", "g": "middle"}, testImg); err == nil {
+		t.Error("Expected error for unknown gravity")
+	}
+
+	if _, err := manipulator.Execute(nil, map[string]string{"w": "20000"}, testImg); err == nil {
+		t.Error("Expected error for a canvas larger than the max image dimension")
+	}
+}
@@ -0,0 +1,37 @@
+package manipulators
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// parsePercentageParam parses an optional 0-100 percentage parameter and returns it as a 0-1 fraction
+func parsePercentageParam(params map[string]string, name string, def float64) (float64, error) {
+	v, ok := params[name]
+	if !ok {
+		return def / 100, nil
+	}
+
+	value, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(value) || value < 0 || value > 100 {
+		return 0, fmt.Errorf("%s must be a value between 0 and 100", name)
+	}
+
+	return value / 100, nil
+}
+
+// parseFloatParam parses an optional number parameter and validates that it falls within min and max
+func parseFloatParam(params map[string]string, name string, def, min, max float64) (float64, error) {
+	v, ok := params[name]
+	if !ok {
+		return def, nil
+	}
+
+	value, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) || value < min || value > max {
+		return 0, fmt.Errorf("%s must be a value between %g and %g", name, min, max)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,60 @@
+package manipulators
+
+import (
+	"testing"
+)
+
+func TestParseFloatParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"in range", "2.5", 2.5, false},
+		{"below range", "0.1", 0, true},
+		{"above range", "51", 0, true},
+		{"not a number", "abc", 0, true},
+		{"NaN", "NaN", 0, true},
+		{"infinity", "Inf", 0, true},
+		{"overflow", "1e400", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFloatParam(map[string]string{"r": tt.value}, "r", 1, 0.5, 50)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFloatParam(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseFloatParam(%q) = %g, want %g", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePercentageParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"in range", "50", 0.5, false},
+		{"above range", "101", 0, true},
+		{"NaN", "NaN", 0, true},
+		{"infinity", "-Inf", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePercentageParam(map[string]string{"p": tt.value}, "p", 100)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePercentageParam(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parsePercentageParam(%q) = %g, want %g", tt.value, got, tt.want)
+			}
+		})
+	}
+}
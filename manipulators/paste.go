@@ -1,92 +1,239 @@
 package manipulators
 
 import (
+	"bytes"
+	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
-	"net/http"
+	_ "image/gif"  // register GIF decoder for overlays
+	_ "image/jpeg" // register JPEG decoder for overlays
+	_ "image/png"  // register PNG decoder for overlays
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 
+	_ "golang.org/x/image/webp" // register WEBP decoder for overlays
+
+	"github.com/anthonynsimon/bild/transform"
 	"github.com/erans/thumbla/config"
+	"github.com/erans/thumbla/fetchers"
+	"github.com/erans/thumbla/middleware"
 	"github.com/gofiber/fiber/v2"
 )
 
-// CropManipulator crops the image
+// Limit the number of tiles drawn as small overlays with no gap would draw one for every few pixels
+const maxPasteTiles = 10000
+
+// PasteManipulator pastes an overlay image on top of the image
+//
+// Supported parameters:
+// - img (string) - overlay reference in the form of fetcherName:path. http/https URLs are fetched using the configured http fetcher
+// - align (string) - anchor of the overlay: topleft, topcenter, topright, centerleft, center, centerright, bottomleft, bottomcenter, bottomright
+// - x, y (int) - offset in pixels from the anchor, applied inwards from the anchored edges
+// - opacity (float 0-100) - opacity of the overlay in percentages (default 100)
+// - s (float 0-100) - scale the overlay width to a percentage of the base image width, keeping proportions
+// - tile (boolean - 0/1) - repeat the overlay across the whole image
+// - gap (int) - spacing in pixels between tiles. Up to 10000 tiles are drawn
 type PasteManipulator struct {
+	Cfg *config.Config
 }
 
-var alignmentRegistry = map[string]func(b image.Rectangle, bb image.Rectangle) image.Rectangle{
-	"topcenter": func(b image.Rectangle, bb image.Rectangle) image.Rectangle {
-		minX := (b.Max.X / 2) - (bb.Max.X / 2)
-		minY := 0
-		maxX := minX + bb.Max.X
-		maxY := minY + bb.Max.Y
-		return image.Rect(minX, minY, maxX, maxY)
-	},
-	"center": func(b image.Rectangle, bb image.Rectangle) image.Rectangle {
-		minX := (b.Max.X / 2) - (bb.Max.X / 2)
-		minY := (b.Max.Y / 2) - (bb.Max.Y / 2)
-		maxX := minX + bb.Max.X
-		maxY := minY + bb.Max.Y
-		return image.Rect(minX, minY, maxX, maxY)
-	},
-	"bottomcenter": func(b image.Rectangle, bb image.Rectangle) image.Rectangle {
-		minX := (b.Max.X / 2) - (bb.Max.X / 2)
-		minY := b.Max.Y - bb.Max.Y
-		maxX := minX + bb.Max.X
-		maxY := b.Max.Y
-		return image.Rect(minX, minY, maxX, maxY)
-	},
-	"centerleft": func(b image.Rectangle, bb image.Rectangle) image.Rectangle {
-		minX := 0
-		minY := (b.Max.Y / 2) - (bb.Max.Y / 2)
-		maxX := minX + bb.Max.X
-		maxY := minY + bb.Max.Y
-		return image.Rect(minX, minY, maxX, maxY)
-	},
-	"centerright": func(b image.Rectangle, bb image.Rectangle) image.Rectangle {
-		minX := b.Max.X - bb.Max.X
-		minY := (b.Max.Y / 2) - (bb.Max.Y / 2)
-		maxX := b.Max.X
-		maxY := minY + bb.Max.Y
-		return image.Rect(minX, minY, maxX, maxY)
-	},
+// fetchOverlay resolves an overlay reference through the fetchers registry and decodes it
+func (manipulator *PasteManipulator) fetchOverlay(c *fiber.Ctx, ref string) (image.Image, error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("overlay reference '%s' must be in the form of fetcherName:path", ref)
+	}
+
+	var fetcher fetchers.Fetcher
+	var fetchURL = parts[1]
+	if parts[0] == "http" || parts[0] == "https" {
+		fetcher = fetchers.GetFetcherByType("http")
+		fetchURL = ref
+	} else {
+		fetcher = fetchers.GetFetcherByName(parts[0])
+	}
+
+	if fetcher == nil {
+		return nil, fmt.Errorf("no fetcher is configured for overlay reference '%s'", ref)
+	}
+
+	if c != nil {
+		logger := middleware.GetLoggerFromContext(c)
+		logger.Debug().Str("fetcher", fetcher.GetName()).Str("url", fetchURL).Msg("Fetching overlay image")
+	}
+
+	body, _, err := fetcher.Fetch(c, fetchURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch overlay image: %w", err)
+	}
+
+	if body == nil {
+		return nil, fmt.Errorf("overlay image '%s' not found", ref)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read overlay image: %w", err)
+	}
+
+	// Check the dimensions before decoding, as decoding allocates the whole image
+	overlayCfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode overlay image: %w", err)
+	}
+
+	maxDimension := manipulator.Cfg.GetMaxImageDimension()
+	if overlayCfg.Width > maxDimension || overlayCfg.Height > maxDimension {
+		return nil, fmt.Errorf("overlay dimensions (%dx%d) exceed maximum allowed size (%dx%d)",
+			overlayCfg.Width, overlayCfg.Height, maxDimension, maxDimension)
+	}
+
+	overlay, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode overlay image: %w", err)
+	}
+
+	return overlay, nil
 }
 
 // Execute runs the paste manipulator
 func (manipulator *PasteManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
-	if imgUrl, ok := params["img"]; ok {
-		response, err := http.Get(imgUrl)
-		if err != nil {
-			return nil, err
+	ref, ok := params["img"]
+	if !ok {
+		return img, nil
+	}
+
+	var err error
+	var margin image.Point
+	if v, ok := params["x"]; ok {
+		if margin.X, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid x offset value")
 		}
+	}
 
-		defer response.Body.Close()
-		pastedImg, _, err := image.Decode(response.Body)
-		if err != nil {
-			return nil, err
+	if v, ok := params["y"]; ok {
+		if margin.Y, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid y offset value")
+		}
+	}
+
+	var opacity = 100.0
+	if v, ok := params["opacity"]; ok {
+		if opacity, err = strconv.ParseFloat(v, 64); err != nil || opacity < 0 || opacity > 100 {
+			return nil, fmt.Errorf("opacity must be a value between 0 and 100")
 		}
+	}
+
+	var scale = 0.0
+	if v, ok := params["s"]; ok {
+		if scale, err = strconv.ParseFloat(v, 64); err != nil || scale <= 0 || scale > 100 {
+			return nil, fmt.Errorf("scale (s) must be a value between 0 and 100")
+		}
+	}
 
-		b := img.Bounds()
-		originalImg := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
-		draw.Draw(originalImg, originalImg.Bounds(), img, b.Min, draw.Src)
+	var gap = 0
+	if v, ok := params["gap"]; ok {
+		if gap, err = strconv.Atoi(v); err != nil || gap < 0 {
+			return nil, fmt.Errorf("invalid gap value")
+		}
+	}
 
+	var align = "topleft"
+	if v, ok := params["align"]; ok {
+		if !isValidGravity(v) {
+			return nil, fmt.Errorf("unknown alignment '%s'", v)
+		}
+		align = v
+	}
+
+	pastedImg, err := manipulator.fetchOverlay(c, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	originalImg := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(originalImg, originalImg.Bounds(), img, b.Min, draw.Src)
+
+	if scale > 0 {
 		bb := pastedImg.Bounds()
-		alignment := image.Rect(0, 0, bb.Max.X, bb.Max.Y)
+		w := int(float64(b.Dx()) * scale / 100.0)
+		h := int(float64(w) * float64(bb.Dy()) / float64(bb.Dx()))
+		if w < 1 || h < 1 {
+			return nil, fmt.Errorf("scaled overlay is smaller than 1 pixel")
+		}
+		// The height follows the overlay proportions, so a narrow overlay can become far taller than the image
+		if err := checkCanvasSize(manipulator.Cfg, image.Pt(w, h)); err != nil {
+			return nil, err
+		}
+		pastedImg = transform.Resize(pastedImg, w, h, transform.Linear)
+	}
 
-		if align, ok := params["align"]; ok {
-			if f, ok := alignmentRegistry[align]; ok {
-				alignment = f(b, bb)
-			}
+	var mask image.Image
+	if opacity < 100 {
+		mask = image.NewUniform(color.Alpha{A: uint8(opacity * 255 / 100)})
+	}
+
+	bb := pastedImg.Bounds()
+	size := bb.Size()
+
+	if val, ok := params["tile"]; ok && val == "1" {
+		stepX := size.X + gap
+		stepY := size.Y + gap
+		start := gravityRect(align, originalImg.Bounds(), size, margin).Min
+
+		// Move the starting point back so that the pattern covers the whole image
+		for start.X > 0 {
+			start.X -= stepX
+		}
+		for start.Y > 0 {
+			start.Y -= stepY
 		}
 
-		draw.Draw(originalImg, alignment, pastedImg, image.Pt(0, 0), draw.Over)
+		cols := (originalImg.Bounds().Max.X - start.X + stepX - 1) / stepX
+		rows := (originalImg.Bounds().Max.Y - start.Y + stepY - 1) / stepY
+		if cols*rows > maxPasteTiles {
+			return nil, fmt.Errorf("tiling requires %d tiles, more than the allowed %d. Use a larger overlay or gap", cols*rows, maxPasteTiles)
+		}
+
+		for y := start.Y; y < originalImg.Bounds().Max.Y; y += stepY {
+			for x := start.X; x < originalImg.Bounds().Max.X; x += stepX {
+				r := image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x+size.X, y+size.Y)}
+				draw.DrawMask(originalImg, r, pastedImg, bb.Min, mask, image.Point{}, draw.Over)
+			}
+		}
 
 		return originalImg, nil
 	}
 
-	return img, nil
+	r := gravityRect(align, originalImg.Bounds(), size, margin)
+	draw.DrawMask(originalImg, r, pastedImg, bb.Min, mask, image.Point{}, draw.Over)
+
+	return originalImg, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *PasteManipulator) Schema() Schema {
+	return Schema{
+		Description: "Pastes an overlay image on top of the image",
+		Params: []ParamSchema{
+			Param("img", ParamString, "overlay reference in the form of fetcherName:path").AsRequired(),
+			EnumParam("align", "anchor of the overlay", slices.Sorted(maps.Keys(gravityRegistry))...).WithDefault("topleft"),
+			Param("x", ParamInt, "horizontal offset in pixels from the anchor").Range(-20000, 20000),
+			Param("y", ParamInt, "vertical offset in pixels from the anchor").Range(-20000, 20000),
+			Param("opacity", ParamFloat, "opacity in percentages").Range(0, 100).WithDefault("100"),
+			Param("s", ParamFloat, "overlay width in percentages of the image width").Range(0, 100),
+			Param("tile", ParamBool, "repeat the overlay across the image"),
+			Param("gap", ParamInt, "spacing in pixels between tiles").Range(0, 20000),
+		},
+	}
 }
 
 // NewPasteManipulator returns a new Paste Manipulator
 func NewPasteManipulator(cfg *config.Config) *PasteManipulator {
-	return &PasteManipulator{}
+	return &PasteManipulator{Cfg: cfg}
 }
@@ -0,0 +1,193 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/erans/thumbla/fetchers"
+	"github.com/gofiber/fiber/v2"
+)
+
+func setupPasteTest(t *testing.T) *PasteManipulator {
+	tempDir := t.TempDir()
+
+	// A 4x4 opaque blue overlay, a narrow one and one larger than the max image dimension
+	overlays := map[string]image.Rectangle{
+		"overlay.png": image.Rect(0, 0, 4, 4),
+		"narrow.png":  image.Rect(0, 0, 1, 100),
+		"huge.png":    image.Rect(0, 0, 10001, 1),
+	}
+
+	for name, bounds := range overlays {
+		overlay := image.NewRGBA(bounds)
+		draw.Draw(overlay, bounds, image.NewUniform(color.RGBA{0, 0, 255, 255}), image.Point{}, draw.Src)
+
+		f, err := os.Create(filepath.Join(tempDir, name))
+		if err != nil {
+			t.Fatalf("Failed to create overlay: %v", err)
+		}
+
+		err = png.Encode(f, overlay)
+		f.Close()
+		if err != nil {
+			t.Fatalf("Failed to encode overlay: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		Fetchers: []map[string]interface{}{
+			{
+				"name": "overlays",
+				"type": "local",
+				"path": tempDir,
+			},
+		},
+	}
+	config.SetConfig(cfg)
+	fetchers.InitFetchers(cfg)
+
+	return NewPasteManipulator(cfg)
+}
+
+func TestPasteManipulator(t *testing.T) {
+	manipulator := setupPasteTest(t)
+
+	testImg := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			testImg.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+
+	tests := []struct {
+		name    string
+		params  map[string]string
+		blueAt  []image.Point
+		redAt   []image.Point
+		opacity bool
+	}{
+		{
+			name:   "default top left",
+			params: map[string]string{"img": "overlays:overlay.png"},
+			blueAt: []image.Point{{0, 0}, {3, 3}},
+			redAt:  []image.Point{{4, 4}},
+		},
+		{
+			name:   "bottom right with offset",
+			params: map[string]string{"img": "overlays:overlay.png", "align": "bottomright", "x": "2", "y": "2"},
+			blueAt: []image.Point{{14, 14}, {17, 17}},
+			redAt:  []image.Point{{18, 18}, {13, 13}},
+		},
+		{
+			name:   "scaled to half the width",
+			params: map[string]string{"img": "overlays:overlay.png", "align": "center", "s": "50"},
+			blueAt: []image.Point{{5, 5}, {14, 14}},
+			redAt:  []image.Point{{4, 4}, {15, 15}},
+		},
+		{
+			name:   "tiled with gap",
+			params: map[string]string{"img": "overlays:overlay.png", "tile": "1", "gap": "4"},
+			blueAt: []image.Point{{0, 0}, {8, 8}, {16, 16}},
+			redAt:  []image.Point{{4, 4}, {12, 12}},
+		},
+		{
+			name:    "half opacity",
+			params:  map[string]string{"img": "overlays:overlay.png", "opacity": "50"},
+			opacity: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *fiber.Ctx
+
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			for _, p := range tt.blueAt {
+				r, _, b, _ := result.At(p.X, p.Y).RGBA()
+				if r != 0 || b != 0xffff {
+					t.Errorf("expected overlay pixel at %v", p)
+				}
+			}
+
+			for _, p := range tt.redAt {
+				r, _, b, _ := result.At(p.X, p.Y).RGBA()
+				if r != 0xffff || b != 0 {
+					t.Errorf("expected base pixel at %v", p)
+				}
+			}
+
+			if tt.opacity {
+				r, _, b, _ := result.At(0, 0).RGBA()
+				if r == 0 || b == 0 {
+					t.Errorf("expected blended pixel, got r=%d b=%d", r, b)
+				}
+			}
+		})
+	}
+}
+
+func TestPasteManipulator_InvalidParams(t *testing.T) {
+	manipulator := setupPasteTest(t)
+
+	testImg := image.NewRGBA(image.Rect(0, 0, 200, 200))
+
+	tests := []struct {
+		name   string
+		params map[string]string
+	}{
+		{
+			name:   "missing fetcher name",
+			params: map[string]string{"img": "overlay.png"},
+		},
+		{
+			name:   "unknown fetcher",
+			params: map[string]string{"img": "missing:overlay.png"},
+		},
+		{
+			name:   "path traversal",
+			params: map[string]string{"img": "overlays:../overlay.png"},
+		},
+		{
+			name:   "unknown alignment",
+			params: map[string]string{"img": "overlays:overlay.png", "align": "middle"},
+		},
+		{
+			name:   "invalid opacity",
+			params: map[string]string{"img": "overlays:overlay.png", "opacity": "150"},
+		},
+		{
+			name:   "overlay larger than the max dimension",
+			params: map[string]string{"img": "overlays:huge.png"},
+		},
+		{
+			name:   "scaled overlay taller than the max dimension",
+			params: map[string]string{"img": "overlays:narrow.png", "s": "100"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *fiber.Ctx
+
+			if _, err := manipulator.Execute(c, tt.params, testImg); err == nil {
+				t.Error("Expected error for invalid params")
+			}
+		})
+	}
+
+	// A 4x4 overlay with no gap would be drawn 125x125 times
+	largeImg := image.NewRGBA(image.Rect(0, 0, 500, 500))
+	if _, err := manipulator.Execute(nil, map[string]string{"img": "overlays:overlay.png", "tile": "1"}, largeImg); err == nil {
+		t.Error("Expected error for too many tiles")
+	}
+}
@@ -0,0 +1,169 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PerspectiveManipulator maps four source corners onto four destination corners, e.g. to straighten a
+// photographed document or to place a screenshot on a tilted screen
+//
+// Supported parameters:
+// - from (string) - source corners x1|y1|x2|y2|x3|y3|x4|y4 in the order top-left, top-right, bottom-right, bottom-left (default the image corners)
+// - to (string) - destination corners in the same format. Defaults to an upright rectangle sized after the source corners
+// - w, h (int) - size of the result. Defaults to the bounding box of the destination corners
+// - interp (string) - nearest, bilinear (default) or bicubic interpolation
+// - bg (color) - color of the areas not covered by the image (default transparent)
+type PerspectiveManipulator struct {
+	Cfg *config.Config
+}
+
+// solveHomography returns the coefficients of the projective transformation mapping every point in from to the
+// matching point in to
+func solveHomography(from, to []float64) ([8]float64, error) {
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y := from[i*2], from[i*2+1]
+		u, v := to[i*2], to[i*2+1]
+		a[i*2] = [9]float64{x, y, 1, 0, 0, 0, -x * u, -y * u, u}
+		a[i*2+1] = [9]float64{0, 0, 0, x, y, 1, -x * v, -y * v, v}
+	}
+
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-9 {
+			return [8]float64{}, fmt.Errorf("perspective corners must not be collinear")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	var h [8]float64
+	for i := range h {
+		h[i] = a[i][8] / a[i][i]
+	}
+	return h, nil
+}
+
+// Execute runs the perspective manipulator
+func (manipulator *PerspectiveManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	src := toNRGBA(img)
+	w := float64(src.Bounds().Dx())
+	h := float64(src.Bounds().Dy())
+
+	_, hasFrom := params["from"]
+	_, hasTo := params["to"]
+	if !hasFrom && !hasTo {
+		return nil, fmt.Errorf("perspective requires from and/or to parameters")
+	}
+
+	var err error
+	var from = []float64{0, 0, w, 0, w, h, 0, h}
+	if v, ok := params["from"]; ok {
+		if from, err = parseFloats("from", v, 8); err != nil {
+			return nil, err
+		}
+	}
+
+	var to []float64
+	if v, ok := params["to"]; ok {
+		if to, err = parseFloats("to", v, 8); err != nil {
+			return nil, err
+		}
+	} else {
+		dist := func(i, j int) float64 {
+			return math.Hypot(from[j*2]-from[i*2], from[j*2+1]-from[i*2+1])
+		}
+		rw := math.Round(math.Max(dist(0, 1), dist(3, 2)))
+		rh := math.Round(math.Max(dist(0, 3), dist(1, 2)))
+		to = []float64{0, 0, rw, 0, rw, rh, 0, rh}
+	}
+
+	var size image.Point
+	for i := 0; i < 4; i++ {
+		size.X = int(math.Max(float64(size.X), math.Ceil(to[i*2])))
+		size.Y = int(math.Max(float64(size.Y), math.Ceil(to[i*2+1])))
+	}
+
+	dims := map[string]*int{"w": &size.X, "h": &size.Y}
+	for name, target := range dims {
+		if v, ok := params[name]; ok {
+			if *target, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("invalid %s value '%s'", name, v)
+			}
+		}
+	}
+
+	if err := checkCanvasSize(manipulator.Cfg, size); err != nil {
+		return nil, err
+	}
+
+	interpolation, err := parseInterpolation(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var bg = namedColors["transparent"]
+	if v, ok := params["bg"]; ok {
+		if bg, err = parseColor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	// Map destination pixels back to the source
+	m, err := solveHomography(to, from)
+	if err != nil {
+		return nil, err
+	}
+
+	inverse := func(x, y float64) (float64, float64) {
+		d := m[6]*x + m[7]*y + 1
+		if d <= 0 {
+			// Behind the horizon of the projection
+			return math.NaN(), math.NaN()
+		}
+		return (m[0]*x + m[1]*y + m[2]) / d, (m[3]*x + m[4]*y + m[5]) / d
+	}
+
+	return warpImage(src, size, inverse, interpolation, bg), nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *PerspectiveManipulator) Schema() Schema {
+	return Schema{
+		Description: "Maps four source corners onto four destination corners",
+		Params: []ParamSchema{
+			Param("from", ParamString, "source corners in the form of x1|y1|x2|y2|x3|y3|x4|y4"),
+			Param("to", ParamString, "destination corners in the form of x1|y1|x2|y2|x3|y3|x4|y4"),
+			Param("w", ParamInt, "width of the result in pixels").Range(1, 20000),
+			Param("h", ParamInt, "height of the result in pixels").Range(1, 20000),
+			interpolationParam(),
+			Param("bg", ParamColor, "color of the areas not covered by the image").WithDefault("transparent"),
+		},
+	}
+}
+
+// NewPerspectiveManipulator returns a new perspective Manipulator
+func NewPerspectiveManipulator(cfg *config.Config) *PerspectiveManipulator {
+	return &PerspectiveManipulator{Cfg: cfg}
+}
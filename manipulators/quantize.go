@@ -0,0 +1,271 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	ditherFloydSteinberg = "fs"
+	ditherNone           = "none"
+)
+
+// QuantizeManipulator reduces the image to a limited number of colors, either an adaptive palette or a fixed
+// palette declared in the "palettes" section of the config. The result is a paletted image, which is written
+// as an 8-bit PNG
+//
+// Supported parameters:
+// - colors (int 2-256) - number of colors of the adaptive palette (default 16)
+// - palette (string) - name of a fixed palette as declared in the config. Overrides colors
+// - dither (string) - fs for Floyd-Steinberg error diffusion or none (default)
+type QuantizeManipulator struct {
+	Palettes map[string]color.Palette
+}
+
+// colorBucket accumulates the pixels that fall into the same reduced precision color
+type colorBucket struct {
+	r, g, b, count float64
+}
+
+func (bucket colorBucket) channel(ch int) float64 {
+	switch ch {
+	case 0:
+		return bucket.r / bucket.count
+	case 1:
+		return bucket.g / bucket.count
+	}
+	return bucket.b / bucket.count
+}
+
+// colorBox is a set of buckets the median cut algorithm splits
+type colorBox []colorBucket
+
+// widestChannel returns the channel with the largest range in the box and its range
+func (box colorBox) widestChannel() (int, float64) {
+	var widest int
+	var widestRange float64
+	for ch := 0; ch < 3; ch++ {
+		min, max := 255.0, 0.0
+		for _, bucket := range box {
+			v := bucket.channel(ch)
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		if max-min > widestRange {
+			widest, widestRange = ch, max-min
+		}
+	}
+	return widest, widestRange
+}
+
+// average returns the pixel weighted average color of the box
+func (box colorBox) average() color.NRGBA {
+	var sum colorBucket
+	for _, bucket := range box {
+		sum.r += bucket.r
+		sum.g += bucket.g
+		sum.b += bucket.b
+		sum.count += bucket.count
+	}
+	return color.NRGBA{clampChannel(sum.r / sum.count), clampChannel(sum.g / sum.count), clampChannel(sum.b / sum.count), 255}
+}
+
+// medianCutPalette builds an adaptive palette of up to n colors from the visible pixels of the image
+func medianCutPalette(img *image.NRGBA, n int) color.Palette {
+	// Group the colors at 5 bits per channel to keep the number of buckets small
+	buckets := map[uint16]*colorBucket{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			px := img.Pix[i : i+4 : i+4]
+			if px[3] < 128 {
+				continue
+			}
+
+			key := uint16(px[0]>>3)<<10 | uint16(px[1]>>3)<<5 | uint16(px[2]>>3)
+			bucket, ok := buckets[key]
+			if !ok {
+				bucket = &colorBucket{}
+				buckets[key] = bucket
+			}
+			bucket.r += float64(px[0])
+			bucket.g += float64(px[1])
+			bucket.b += float64(px[2])
+			bucket.count++
+		}
+	}
+
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	all := make(colorBox, 0, len(buckets))
+	for _, bucket := range buckets {
+		all = append(all, *bucket)
+	}
+
+	boxes := []colorBox{all}
+	for len(boxes) < n {
+		// Split the box with the widest color range
+		split := -1
+		var splitChannel int
+		var splitRange float64
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, r := box.widestChannel(); r > splitRange {
+				split, splitChannel, splitRange = i, ch, r
+			}
+		}
+
+		if split < 0 {
+			break
+		}
+
+		box := boxes[split]
+		sort.Slice(box, func(i, j int) bool { return box[i].channel(splitChannel) < box[j].channel(splitChannel) })
+
+		var total, count float64
+		for _, bucket := range box {
+			total += bucket.count
+		}
+
+		// Split at the pixel weighted median, keeping at least one bucket on each side
+		median := 1
+		for i, bucket := range box[:len(box)-1] {
+			count += bucket.count
+			if count >= total/2 {
+				median = i + 1
+				break
+			}
+		}
+
+		boxes[split] = box[:median]
+		boxes = append(boxes, box[median:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		palette = append(palette, box.average())
+	}
+	return palette
+}
+
+// hasTransparency returns true if the image has pixels that are mostly transparent
+func hasTransparency(img *image.NRGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] < 128 {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute runs the quantize manipulator
+func (manipulator *QuantizeManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var err error
+	var colors = 16
+	if v, ok := params["colors"]; ok {
+		if colors, err = strconv.Atoi(v); err != nil || colors < 2 || colors > 256 {
+			return nil, fmt.Errorf("colors must be a value between 2 and 256")
+		}
+	}
+
+	var drawer draw.Drawer = draw.Src
+	if v, ok := params["dither"]; ok {
+		switch v {
+		case ditherFloydSteinberg:
+			drawer = draw.FloydSteinberg
+		case ditherNone:
+		default:
+			return nil, fmt.Errorf("unknown dither mode '%s'", v)
+		}
+	}
+
+	src := toNRGBA(img)
+	transparent := hasTransparency(src)
+
+	var palette color.Palette
+	if name, ok := params["palette"]; ok {
+		fixed, ok := manipulator.Palettes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown palette '%s'", name)
+		}
+		palette = append(palette, fixed...)
+	} else {
+		if transparent {
+			// Keep a slot for the transparent color
+			colors--
+		}
+		palette = medianCutPalette(src, colors)
+	}
+
+	if transparent && len(palette) < 256 {
+		palette = append(palette, color.NRGBA{})
+	}
+
+	if len(palette) == 0 {
+		palette = color.Palette{color.NRGBA{}}
+	}
+
+	result := image.NewPaletted(src.Bounds(), palette)
+	drawer.Draw(result, result.Bounds(), src, image.Point{})
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *QuantizeManipulator) Schema() Schema {
+	return Schema{
+		Description: "Reduces the image to a limited number of colors",
+		Params: []ParamSchema{
+			Param("colors", ParamInt, "number of colors of the adaptive palette").Range(2, 256).WithDefault("16"),
+			EnumParam("palette", "fixed palette name", slices.Sorted(maps.Keys(manipulator.Palettes))...),
+			EnumParam("dither", "dithering", "fs", "none").WithDefault("none"),
+		},
+	}
+}
+
+// NewQuantizeManipulator returns a new quantize Manipulator, loading the palettes declared in the config
+func NewQuantizeManipulator(cfg *config.Config) *QuantizeManipulator {
+	palettes := map[string]color.Palette{}
+	for _, paletteCfg := range cfg.Palettes {
+		if len(paletteCfg.Colors) == 0 || len(paletteCfg.Colors) > 256 {
+			log.Printf("Palette '%s' must have between 1 and 256 colors", paletteCfg.Name)
+			continue
+		}
+
+		palette := make(color.Palette, 0, len(paletteCfg.Colors))
+		for _, value := range paletteCfg.Colors {
+			c, err := parseColor(value)
+			if err != nil {
+				log.Printf("Failed to parse color '%s' of palette '%s': %v", value, paletteCfg.Name, err)
+				palette = nil
+				break
+			}
+			palette = append(palette, c)
+		}
+
+		if palette != nil {
+			palettes[paletteCfg.Name] = palette
+		}
+	}
+
+	return &QuantizeManipulator{Palettes: palettes}
+}
@@ -0,0 +1,102 @@
+package manipulators
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestQuantizeManipulator(t *testing.T) {
+	cfg := &config.Config{
+		Palettes: []config.PaletteConfig{
+			{Name: "bw", Colors: []string{"000000", "ffffff"}},
+			{Name: "broken", Colors: []string{"000000", "nocolor"}},
+		},
+	}
+	manipulator := NewQuantizeManipulator(cfg)
+
+	if _, ok := manipulator.Palettes["broken"]; ok {
+		t.Errorf("expected a palette with an invalid color to be skipped")
+	}
+
+	testImg := newGradientImage(64, 8, 0, 255, [3]int{0, 10, -10})
+
+	var c *fiber.Ctx
+	tests := []struct {
+		name      string
+		params    map[string]string
+		maxColors int
+		wantErr   bool
+	}{
+		{"default", map[string]string{}, 16, false},
+		{"4 colors dithered", map[string]string{"colors": "4", "dither": "fs"}, 4, false},
+		{"fixed palette", map[string]string{"palette": "bw", "dither": "none"}, 2, false},
+		{"invalid colors", map[string]string{"colors": "1"}, 0, true},
+		{"unknown palette", map[string]string{"palette": "brand"}, 0, true},
+		{"unknown dither", map[string]string{"dither": "ordered"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			paletted, ok := result.(*image.Paletted)
+			if !ok {
+				t.Fatalf("expected a paletted image, got %T", result)
+			}
+
+			if len(paletted.Palette) > tt.maxColors || len(paletted.Palette) < 2 {
+				t.Errorf("palette has %d colors, want 2-%d", len(paletted.Palette), tt.maxColors)
+			}
+
+			if paletted.Bounds() != testImg.Bounds() {
+				t.Errorf("bounds = %v, want %v", paletted.Bounds(), testImg.Bounds())
+			}
+		})
+	}
+}
+
+func TestQuantizeManipulator_Transparency(t *testing.T) {
+	manipulator := NewQuantizeManipulator(&config.Config{})
+
+	testImg := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	testImg.Set(1, 1, color.NRGBA{255, 0, 0, 255})
+	testImg.Set(2, 2, color.NRGBA{0, 0, 255, 255})
+
+	var c *fiber.Ctx
+	result, err := manipulator.Execute(c, map[string]string{"colors": "3"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if a := alphaAt(result, 0, 0); a != 0 {
+		t.Errorf("expected transparent pixels to stay transparent, got alpha %d", a)
+	}
+	if got := color.NRGBAModel.Convert(result.At(1, 1)); got != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the red pixel to be kept, got %v", got)
+	}
+
+	// Paletted images are encoded as 8-bit PNGs
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, result); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	if _, ok := decoded.(*image.Paletted); !ok {
+		t.Errorf("expected an 8-bit PNG, got %T", decoded)
+	}
+}
@@ -0,0 +1,199 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/anthonynsimon/bild/blur"
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	redactModePixelate = "pixelate"
+	redactModeBlur     = "blur"
+)
+
+// Limit the strength as the cost of blurring grows with the radius
+const maxRedactStrength = 500
+
+// RedactManipulator anonymizes detected faces or explicitly given regions by pixelating or blurring them
+//
+// Supported parameters:
+// - mode (string) - pixelate (default) or blur
+// - s (float) - strength: block size in pixels for pixelate, blur radius for blur, up to 500. Defaults to a value relative to the region size
+// - pp (float 0.x-1.0) - factor with which to enlarge each region (default 0.1 = 10%)
+// - rects (string) - explicit regions in the form of x|y|w|h separated by ';'. Face detection is skipped when given
+// - provider (string) - force a specific face detection provider as defined in the config
+// - useCache (boolean - 0/1) - enable/disable the use of cache for the Facial detection API result
+type RedactManipulator struct {
+	Cfg *config.Config
+}
+
+// parseRects parses regions given in the form of x|y|w|h;x|y|w|h
+func parseRects(value string) ([]image.Rectangle, error) {
+	var rects []image.Rectangle
+	for _, r := range strings.Split(value, ";") {
+		if r == "" {
+			continue
+		}
+
+		parts := strings.Split(r, "|")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("region '%s' must have 4 values separated by a '|' sign", r)
+		}
+
+		var values [4]int
+		for i, p := range parts {
+			v, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid region value '%s'", p)
+			}
+			values[i] = v
+		}
+
+		if values[2] <= 0 || values[3] <= 0 {
+			return nil, fmt.Errorf("region '%s' must have a positive width and height", r)
+		}
+
+		rects = append(rects, image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3]))
+	}
+
+	return rects, nil
+}
+
+// pixelate replaces every block in the region with its average color
+func pixelate(img *image.NRGBA, region image.Rectangle, blockSize int) {
+	for by := region.Min.Y; by < region.Max.Y; by += blockSize {
+		for bx := region.Min.X; bx < region.Max.X; bx += blockSize {
+			block := image.Rect(bx, by, bx+blockSize, by+blockSize).Intersect(region)
+
+			var r, g, b, a, count float64
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					i := img.PixOffset(x, y)
+					alpha := float64(img.Pix[i+3])
+					r += float64(img.Pix[i]) * alpha
+					g += float64(img.Pix[i+1]) * alpha
+					b += float64(img.Pix[i+2]) * alpha
+					a += alpha
+					count++
+				}
+			}
+
+			var avg [4]uint8
+			if a > 0 {
+				avg = [4]uint8{uint8(r / a), uint8(g / a), uint8(b / a), uint8(a / count)}
+			}
+
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					i := img.PixOffset(x, y)
+					copy(img.Pix[i:i+4], avg[:])
+				}
+			}
+		}
+	}
+}
+
+// Execute runs the redact manipulator
+func (manipulator *RedactManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var err error
+	var mode = redactModePixelate
+	if v, ok := params["mode"]; ok {
+		if v != redactModePixelate && v != redactModeBlur {
+			return nil, fmt.Errorf("unknown redaction mode '%s'", v)
+		}
+		mode = v
+	}
+
+	var strength = 0.0
+	if v, ok := params["s"]; ok {
+		if strength, err = strconv.ParseFloat(v, 64); err != nil || strength <= 0 || strength > maxRedactStrength {
+			return nil, fmt.Errorf("strength (s) must be a positive number up to %d", maxRedactStrength)
+		}
+	}
+
+	var padding = 0.1
+	if v, ok := params["pp"]; ok {
+		if padding, err = strconv.ParseFloat(v, 64); err != nil || padding < 0 {
+			return nil, fmt.Errorf("invalid padding (pp) value")
+		}
+	}
+
+	var regions []image.Rectangle
+	if v, ok := params["rects"]; ok {
+		if regions, err = parseRects(v); err != nil {
+			return nil, err
+		}
+	} else {
+		var provider = manipulator.Cfg.FaceAPI.DefaultProvider
+		if p, ok := params["provider"]; ok {
+			provider = p
+		}
+
+		// Failing to detect faces must not publish an image that was supposed to be anonymized
+		if regions, err = detectFaces(c, manipulator.Cfg, provider, params, img); err != nil {
+			return nil, fmt.Errorf("failed to detect faces: %w", err)
+		}
+	}
+
+	if len(regions) == 0 {
+		return img, nil
+	}
+
+	result := toNRGBA(img)
+	offset := img.Bounds().Min
+
+	for _, region := range regions {
+		region = region.Sub(offset)
+		region = region.Inset(-int(math.Max(float64(region.Dx()), float64(region.Dy())) * padding))
+		region = region.Intersect(result.Bounds())
+		if region.Empty() {
+			continue
+		}
+
+		size := math.Max(float64(region.Dx()), float64(region.Dy()))
+		switch mode {
+		case redactModePixelate:
+			blockSize := strength
+			if blockSize == 0 {
+				blockSize = math.Max(4, size/8)
+			}
+			pixelate(result, region, int(math.Max(1, blockSize)))
+		case redactModeBlur:
+			radius := strength
+			if radius == 0 {
+				radius = math.Max(4, size/10)
+			}
+			blurred := blur.Gaussian(result.SubImage(region), radius)
+			draw.Draw(result, region, blurred, blurred.Bounds().Min, draw.Src)
+		}
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *RedactManipulator) Schema() Schema {
+	return Schema{
+		Description: "Pixelates or blurs faces or given regions",
+		Params: []ParamSchema{
+			EnumParam("mode", "redaction method", redactModePixelate, redactModeBlur).WithDefault(redactModePixelate),
+			Param("s", ParamFloat, "block size or blur radius in pixels").Range(0, maxRedactStrength),
+			Param("pp", ParamFloat, "factor with which to enlarge each region").AtLeast(0).WithDefault("0.1"),
+			Param("rects", ParamString, "regions in the form of x|y|w|h separated by ';'"),
+			Param("provider", ParamString, "face detection provider as defined in the config"),
+			Param("useCache", ParamBool, "cache the face detection result"),
+		},
+	}
+}
+
+// NewRedactManipulator returns a new redact Manipulator
+func NewRedactManipulator(cfg *config.Config) *RedactManipulator {
+	return &RedactManipulator{Cfg: cfg}
+}
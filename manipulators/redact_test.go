@@ -0,0 +1,104 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newCheckerboardImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if (x+y)%2 == 0 {
+				img.Set(x, y, color.NRGBA{0, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.NRGBA{255, 255, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestRedactManipulator(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewRedactManipulator(cfg)
+	testImg := newCheckerboardImage(20, 20)
+
+	tests := []struct {
+		name   string
+		params map[string]string
+	}{
+		{
+			name:   "pixelate region",
+			params: map[string]string{"rects": "4|4|8|8", "s": "4", "pp": "0"},
+		},
+		{
+			name:   "blur region",
+			params: map[string]string{"rects": "4|4|8|8", "mode": "blur", "s": "2", "pp": "0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *fiber.Ctx
+
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			// Pixels inside the region should no longer be pure black or white
+			r, _, _, _ := result.At(8, 8).RGBA()
+			if r == 0 || r == 0xffff {
+				t.Errorf("expected redacted pixel at (8, 8), got r=%d", r>>8)
+			}
+
+			// Pixels outside the region should be untouched
+			if result.At(0, 0) != testImg.At(0, 0) || result.At(15, 15) != testImg.At(15, 15) {
+				t.Error("expected pixels outside of the region to be untouched")
+			}
+		})
+	}
+}
+
+func TestRedactManipulator_InvalidParams(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewRedactManipulator(cfg)
+	testImg := newCheckerboardImage(20, 20)
+
+	tests := []struct {
+		name   string
+		params map[string]string
+	}{
+		{
+			name:   "unknown mode",
+			params: map[string]string{"rects": "0|0|5|5", "mode": "smudge"},
+		},
+		{
+			name:   "malformed region",
+			params: map[string]string{"rects": "0|0|5"},
+		},
+		{
+			name:   "strength out of range",
+			params: map[string]string{"rects": "0|0|5|5", "mode": "blur", "s": "100000000"},
+		},
+		{
+			name:   "unknown face detection provider",
+			params: map[string]string{"provider": "unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *fiber.Ctx
+
+			if _, err := manipulator.Execute(c, tt.params, testImg); err == nil {
+				t.Error("Expected error for invalid params")
+			}
+		})
+	}
+}
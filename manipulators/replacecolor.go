@@ -0,0 +1,93 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReplaceColorManipulator replaces a range of colors with another color, keeping the shading of the original pixels
+//
+// Supported parameters:
+// - from (color) - color to replace
+// - to (color) - replacement color
+// - tol (float 0-100) - color distance in percentages under which pixels are fully replaced (default 10)
+// - soft (float 0-100) - additional color distance over which the replacement fades out (default 10)
+type ReplaceColorManipulator struct {
+}
+
+// Execute runs the replace color manipulator
+func (manipulator *ReplaceColorManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	fromValue, hasFrom := params["from"]
+	toValue, hasTo := params["to"]
+	if !hasFrom || !hasTo {
+		return nil, fmt.Errorf("replacecolor requires from and to parameters")
+	}
+
+	from, err := parseColor(fromValue)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseColor(toValue)
+	if err != nil {
+		return nil, err
+	}
+
+	tolerance, err := parsePercentageParam(params, "tol", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	softness, err := parsePercentageParam(params, "soft", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	shift := [3]float64{
+		float64(to.R) - float64(from.R),
+		float64(to.G) - float64(from.G),
+		float64(to.B) - float64(from.B),
+	}
+
+	result := toNRGBA(img)
+	b := result.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := result.PixOffset(x, y)
+			px := result.Pix[i : i+4 : i+4]
+
+			weight := colorRangeWeight(colorDistance(float64(px[0]), float64(px[1]), float64(px[2]), from), tolerance, softness)
+			if weight <= 0 {
+				continue
+			}
+
+			for ch := 0; ch < 3; ch++ {
+				px[ch] = uint8(math.Max(0, math.Min(255, math.Round(float64(px[ch])+shift[ch]*weight))))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *ReplaceColorManipulator) Schema() Schema {
+	return Schema{
+		Description: "Replaces a range of colors with another color",
+		Params: []ParamSchema{
+			Param("from", ParamColor, "color to replace").AsRequired(),
+			Param("to", ParamColor, "replacement color").AsRequired(),
+			Param("tol", ParamFloat, "color distance in percentages of fully replaced pixels").Range(0, 100).WithDefault("10"),
+			Param("soft", ParamFloat, "color distance in percentages over which the replacement fades out").Range(0, 100).WithDefault("10"),
+		},
+	}
+}
+
+// NewReplaceColorManipulator returns a new replace color Manipulator
+func NewReplaceColorManipulator(cfg *config.Config) *ReplaceColorManipulator {
+	return &ReplaceColorManipulator{}
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"image"
 	"log"
+	"maps"
 	"math"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/anthonynsimon/bild/transform"
 	"github.com/erans/thumbla/config"
@@ -15,6 +18,7 @@ import (
 
 // ResizeManipulator resizes the image based on given parameters. If only 1 parameter is given, proportions are saved
 type ResizeManipulator struct {
+	Cfg *config.Config
 }
 
 var resamplingFilters = map[string]transform.ResampleFilter{
@@ -27,10 +31,19 @@ var resamplingFilters = map[string]transform.ResampleFilter{
 	"lanczos":           transform.Lanczos,
 }
 
+const (
+	resizeModeMin = "min"
+	resizeModeMax = "max"
+)
+
 // Execute runs the resize manipulator and resizes the image. If only width or height are specified, image proportions will be saved
 // w - Width
 // h - Height
 // r - resampling filter (one of resamplingFilters values)
+// scale - scale both dimensions by a percentage of the image size (e.g. 50%), up to 1000%. Can't be combined with w or h
+// mode - min or max. When both w and h are given, keep the image proportions while covering (min) or fitting inside (max) the w x h box
+// upscale - 0/1. When 0 the image is never enlarged beyond its original size (default 1)
+// maxpx - limit the total number of pixels (width x height) of the result, keeping its proportions
 func (manipulator *ResizeManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
 	var width = -1.0
 	var height = -1.0
@@ -58,6 +71,23 @@ func (manipulator *ResizeManipulator) Execute(c *fiber.Ctx, params map[string]st
 		}
 	}
 
+	imgWidth := img.Bounds().Size().X
+	imgHeight := img.Bounds().Size().Y
+
+	if temp, ok = params["scale"]; ok {
+		if width > 0 || height > 0 {
+			return nil, fmt.Errorf("scale can't be combined with width or height")
+		}
+
+		scale, err := strconv.ParseFloat(strings.TrimSuffix(temp, "%"), 64)
+		if err != nil || scale <= 0 || scale > 1000 {
+			return nil, fmt.Errorf("scale must be a positive percentage up to 1000%%")
+		}
+
+		width = math.Max(1, math.Round(float64(imgWidth)*scale/100))
+		height = math.Max(1, math.Round(float64(imgHeight)*scale/100))
+	}
+
 	if width < 0 && height < 0 {
 		return nil, fmt.Errorf("both width and height are less than 0")
 	}
@@ -73,8 +103,24 @@ func (manipulator *ResizeManipulator) Execute(c *fiber.Ctx, params map[string]st
 		return img, fmt.Errorf("height must be at least 1 pixel")
 	}
 
-	imgWidth := img.Bounds().Size().X
-	imgHeight := img.Bounds().Size().Y
+	if temp, ok = params["mode"]; ok {
+		if temp != resizeModeMin && temp != resizeModeMax {
+			return nil, fmt.Errorf("unknown resize mode '%s'", temp)
+		}
+
+		if width < 0 || height < 0 {
+			return nil, fmt.Errorf("resize mode requires both width and height")
+		}
+
+		factor := math.Min(width/float64(imgWidth), height/float64(imgHeight))
+		if temp == resizeModeMin {
+			factor = math.Max(width/float64(imgWidth), height/float64(imgHeight))
+		}
+
+		width = math.Max(1, math.Round(float64(imgWidth)*factor))
+		height = math.Max(1, math.Round(float64(imgHeight)*factor))
+	}
+
 	var ratio = math.Max(float64(imgWidth), float64(imgHeight)) / math.Min(float64(imgWidth), float64(imgHeight))
 
 	log.Printf("resize: ratio=%f", ratio)
@@ -97,14 +143,59 @@ func (manipulator *ResizeManipulator) Execute(c *fiber.Ctx, params map[string]st
 		}
 	}
 
+	if params["upscale"] == "0" && (width > float64(imgWidth) || height > float64(imgHeight)) {
+		factor := math.Min(float64(imgWidth)/width, float64(imgHeight)/height)
+		width = math.Max(1, math.Round(width*factor))
+		height = math.Max(1, math.Round(height*factor))
+	}
+
+	if temp, ok = params["maxpx"]; ok {
+		maxPixels, err := strconv.ParseFloat(temp, 64)
+		if err != nil || maxPixels < 1 {
+			return nil, fmt.Errorf("maxpx must be a positive number")
+		}
+
+		if width*height > maxPixels {
+			factor := math.Sqrt(maxPixels / (width * height))
+			width = math.Max(1, math.Floor(width*factor))
+			height = math.Max(1, math.Floor(height*factor))
+		}
+	}
+
 	log.Printf("Original w: %d  h: %d   New w: %d h: %d  %fx%f", imgWidth, imgHeight, int(width), int(height), width, height)
 
+	if int(width) == imgWidth && int(height) == imgHeight {
+		return img, nil
+	}
+
+	// The other dimension, mode and scale are derived from the image proportions, so the result may exceed the
+	// limits of w and h
+	if err := checkCanvasSize(manipulator.Cfg, image.Pt(int(width), int(height))); err != nil {
+		return nil, err
+	}
+
 	img = transform.Resize(img, int(width), int(height), resamplingFilter)
 
 	return img, nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *ResizeManipulator) Schema() Schema {
+	return Schema{
+		Description: "Resizes the image, keeping its proportions when only one dimension is given",
+		Params: []ParamSchema{
+			Param("w", ParamFloat, "width in pixels").Range(1, 20000),
+			Param("h", ParamFloat, "height in pixels").Range(1, 20000),
+			EnumParam("r", "resampling filter", slices.Sorted(maps.Keys(resamplingFilters))...).WithDefault("linear"),
+			Param("scale", ParamPercent, "scale both dimensions by a percentage of the image size").Range(0, 1000),
+			EnumParam("mode", "cover (min) or fit inside (max) the w x h box", resizeModeMin, resizeModeMax),
+			Param("upscale", ParamBool, "allow enlarging the image beyond its original size").WithDefault("1"),
+			Param("maxpx", ParamInt, "maximal total number of pixels").AtLeast(1),
+		},
+	}
+}
+
 // NewResizeManipulator returns a new Resize Manipulator
 func NewResizeManipulator(cfg *config.Config) *ResizeManipulator {
-	return &ResizeManipulator{}
+	return &ResizeManipulator{Cfg: cfg}
 }
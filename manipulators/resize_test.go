@@ -108,4 +108,56 @@ func TestResizeManipulator_InvalidParams(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestResizeManipulator_Limits(t *testing.T) {
+	manipulator := NewResizeManipulator(&config.Config{})
+	testImg := image.NewRGBA(image.Rect(0, 0, 40, 20))
+
+	tests := []struct {
+		name     string
+		params   map[string]string
+		expected image.Rectangle
+		wantErr  bool
+	}{
+		{"scale down", map[string]string{"scale": "50%"}, image.Rect(0, 0, 20, 10), false},
+		{"scale up without percent sign", map[string]string{"scale": "150"}, image.Rect(0, 0, 60, 30), false},
+		{"max mode fits inside", map[string]string{"w": "30", "h": "30", "mode": "max"}, image.Rect(0, 0, 30, 15), false},
+		{"min mode covers", map[string]string{"w": "30", "h": "30", "mode": "min"}, image.Rect(0, 0, 60, 30), false},
+		{"no upscale", map[string]string{"w": "80", "upscale": "0"}, image.Rect(0, 0, 40, 20), false},
+		{"no upscale keeps requested proportions", map[string]string{"w": "80", "h": "20", "upscale": "0"}, image.Rect(0, 0, 40, 10), false},
+		{"no upscale when shrinking", map[string]string{"w": "20", "upscale": "0"}, image.Rect(0, 0, 20, 10), false},
+		{"max pixels", map[string]string{"w": "400", "maxpx": "800"}, image.Rect(0, 0, 40, 20), false},
+		{"scale with width", map[string]string{"scale": "50", "w": "10"}, image.Rectangle{}, true},
+		{"invalid scale", map[string]string{"scale": "-5%"}, image.Rectangle{}, true},
+		{"scale out of range", map[string]string{"scale": "1001%"}, image.Rectangle{}, true},
+		{"mode without height", map[string]string{"w": "30", "mode": "max"}, image.Rectangle{}, true},
+		{"unknown mode", map[string]string{"w": "30", "h": "30", "mode": "cover"}, image.Rectangle{}, true},
+		{"invalid max pixels", map[string]string{"w": "30", "maxpx": "0"}, image.Rectangle{}, true},
+		{"min mode beyond max dimension", map[string]string{"w": "20000", "h": "1", "mode": "min"}, image.Rectangle{}, true},
+		{"proportional height beyond max dimension", map[string]string{"h": "20000"}, image.Rectangle{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *fiber.Ctx
+
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if result.Bounds() != tt.expected {
+				t.Errorf("Execute() bounds = %v, expected %v", result.Bounds(), tt.expected)
+			}
+		})
+	}
+	// A scale within its range may still exceed the max image dimension
+	limited := NewResizeManipulator(&config.Config{Server: config.ServerConfig{MaxImageDimension: 100}})
+	if _, err := limited.Execute(nil, map[string]string{"scale": "1000%"}, testImg); err == nil {
+		t.Error("Expected error for a scaled size larger than the max image dimension")
+	}
+}
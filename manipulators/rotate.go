@@ -2,7 +2,9 @@ package manipulators
 
 import (
 	"image"
+	"image/draw"
 	"log"
+	"math"
 	"net/url"
 	"strconv"
 	"strings"
@@ -16,20 +18,58 @@ import (
 type RotateManipulator struct {
 }
 
+// rotateQuarters rotates the image clockwise by the given number of quarter turns by transposing its pixels
+func rotateQuarters(img image.Image, quarters int) *image.NRGBA {
+	src := toNRGBA(img)
+	w := src.Bounds().Dx()
+	h := src.Bounds().Dy()
+
+	quarters = ((quarters % 4) + 4) % 4
+	size := image.Pt(w, h)
+	if quarters%2 == 1 {
+		size = image.Pt(h, w)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch quarters {
+			case 0:
+				dx, dy = x, y
+			case 1:
+				dx, dy = h-1-y, x
+			case 2:
+				dx, dy = w-1-x, h-1-y
+			case 3:
+				dx, dy = y, w-1-x
+			}
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
+
 // Execute runs the rotate manipulator and allows rotating an image
 // Supported Parameters:
-// a - Angle = +/- 360.0 (float)
+// a - Angle = +/- 360.0 (float), clockwise. Multiples of 90 degrees are rotated losslessly
 // r - resize bounds = 0/1
 // p - pivot point x|y format
+// bg - color of the areas not covered by the rotated image (default transparent)
 // TODO: support "p" parameter to specify pivot point
 func (manipulator *RotateManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
 	var options = &transform.RotationOptions{ResizeBounds: false, Pivot: nil}
-	var angle = -1.0
+	var angle = 0.0
+	var hasAngle = false
 
 	if val, ok := params["a"]; ok {
 		if i, err := strconv.ParseFloat(val, 64); err == nil {
 			log.Printf("Rotate at %f degress", i)
 			angle = i
+			hasAngle = true
 		}
 	}
 
@@ -55,12 +95,59 @@ func (manipulator *RotateManipulator) Execute(c *fiber.Ctx, params map[string]st
 		}
 	}
 
-	if angle > -1 {
-		rotatedImage := transform.Rotate(img, angle, options)
+	var bg = namedColors["transparent"]
+	if val, ok := params["bg"]; ok {
+		var err error
+		if bg, err = parseColor(val); err != nil {
+			return nil, err
+		}
+	}
+
+	if !hasAngle {
+		return img, nil
+	}
+
+	var rotatedImage image.Image
+	if math.Mod(angle, 90) == 0 && options.Pivot == nil {
+		rotatedImage = rotateQuarters(img, int(math.Mod(angle, 360)/90))
+
+		if options.ResizeBounds || rotatedImage.Bounds().Size() == img.Bounds().Size() {
+			return rotatedImage, nil
+		}
+
+		// Keep the original bounds, centering the rotated image like transform.Rotate does
+		position := image.Pt(
+			(img.Bounds().Dx()-rotatedImage.Bounds().Dx())/2,
+			(img.Bounds().Dy()-rotatedImage.Bounds().Dy())/2,
+		)
+		canvas := image.NewNRGBA(image.Rectangle{Max: img.Bounds().Size()})
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+		draw.Draw(canvas, rotatedImage.Bounds().Add(position), rotatedImage, image.Point{}, draw.Src)
+		return canvas, nil
+	}
+
+	rotatedImage = transform.Rotate(img, angle, options)
+	if bg.A == 0 {
 		return rotatedImage, nil
 	}
 
-	return img, nil
+	canvas := image.NewNRGBA(rotatedImage.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(canvas, canvas.Bounds(), rotatedImage, rotatedImage.Bounds().Min, draw.Over)
+	return canvas, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *RotateManipulator) Schema() Schema {
+	return Schema{
+		Description: "Rotates the image",
+		Params: []ParamSchema{
+			Param("a", ParamFloat, "angle in degrees").Range(-360, 360),
+			Param("r", ParamBool, "resize the canvas to contain the rotated image").WithDefault("0"),
+			Param("p", ParamString, "pivot point in the form of x|y"),
+			Param("bg", ParamColor, "color of the areas not covered by the image").WithDefault("transparent"),
+		},
+	}
 }
 
 // NewRotateManipulator returns a new Rotate Manipulator
@@ -107,4 +107,77 @@ func TestRotateManipulator_InvalidParams(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestRotateManipulator_RightAngles(t *testing.T) {
+	manipulator := NewRotateManipulator(&config.Config{})
+
+	// 4x2 image with a marked top-left pixel
+	testImg := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			testImg.Set(x, y, color.NRGBA{0, 0, 255, 255})
+		}
+	}
+	marker := color.NRGBA{255, 0, 0, 255}
+	testImg.Set(0, 0, marker)
+
+	tests := []struct {
+		name       string
+		params     map[string]string
+		wantBounds image.Rectangle
+		markerAt   image.Point
+	}{
+		{"90 with resize", map[string]string{"a": "90", "r": "1"}, image.Rect(0, 0, 2, 4), image.Pt(1, 0)},
+		{"180", map[string]string{"a": "180"}, image.Rect(0, 0, 4, 2), image.Pt(3, 1)},
+		{"270 with resize", map[string]string{"a": "270", "r": "1"}, image.Rect(0, 0, 2, 4), image.Pt(0, 3)},
+		{"-90 with resize", map[string]string{"a": "-90", "r": "1"}, image.Rect(0, 0, 2, 4), image.Pt(0, 3)},
+		{"450 with resize", map[string]string{"a": "450", "r": "1"}, image.Rect(0, 0, 2, 4), image.Pt(1, 0)},
+	}
+
+	var c *fiber.Ctx
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if result.Bounds() != tt.wantBounds {
+				t.Errorf("bounds = %v, want %v", result.Bounds(), tt.wantBounds)
+			}
+
+			if got := color.NRGBAModel.Convert(result.At(tt.markerAt.X, tt.markerAt.Y)); got != marker {
+				t.Errorf("pixel at %v = %v, want %v", tt.markerAt, got, marker)
+			}
+		})
+	}
+
+	// Without resizing the bounds are kept and areas not covered by the rotated image are filled with the background color
+	result, _ := manipulator.Execute(c, map[string]string{"a": "90", "bg": "white"}, testImg)
+	if result.Bounds() != testImg.Bounds() {
+		t.Errorf("bounds = %v, want %v", result.Bounds(), testImg.Bounds())
+	}
+	if got := color.NRGBAModel.Convert(result.At(0, 0)); got != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("expected the uncovered area to be white, got %v", got)
+	}
+}
+
+func TestRotateManipulator_Background(t *testing.T) {
+	manipulator := NewRotateManipulator(&config.Config{})
+	testImg := newSolidImage(10, 10, color.RGBA{255, 0, 0, 255})
+
+	var c *fiber.Ctx
+	result, err := manipulator.Execute(c, map[string]string{"a": "45", "r": "1", "bg": "00ff00"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if got := color.NRGBAModel.Convert(result.At(0, 0)); got != (color.NRGBA{0, 255, 0, 255}) {
+		t.Errorf("expected the corner to be filled with the background color, got %v", got)
+	}
+
+	if _, err := manipulator.Execute(c, map[string]string{"a": "45", "bg": "nocolor"}, testImg); err == nil {
+		t.Errorf("expected an error for an invalid background color")
+	}
+}
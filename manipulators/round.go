@@ -0,0 +1,102 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RoundManipulator rounds the corners of the image, making the area outside the corners transparent
+//
+// Supported parameters:
+// - r (float) - radius in pixels for all corners, or per corner radii in the form of tl|tr|br|bl
+// - tl, tr, br, bl (float) - radius in pixels of a single corner, overrides the value given in r
+type RoundManipulator struct {
+}
+
+// parseRadii parses the corner radii parameters
+func parseRadii(params map[string]string) (cornerRadii, error) {
+	var radii cornerRadii
+
+	if v, ok := params["r"]; ok {
+		parts := strings.Split(v, "|")
+		values := make([]float64, len(parts))
+		for i, p := range parts {
+			val, err := strconv.ParseFloat(p, 64)
+			if err != nil || val < 0 {
+				return radii, fmt.Errorf("invalid radius (r) value '%s'", v)
+			}
+			values[i] = val
+		}
+
+		switch len(values) {
+		case 1:
+			radii = cornerRadii{values[0], values[0], values[0], values[0]}
+		case 4:
+			radii = cornerRadii{values[0], values[1], values[2], values[3]}
+		default:
+			return radii, fmt.Errorf("radius (r) must have 1 or 4 values separated by a '|' sign")
+		}
+	}
+
+	corners := map[string]*float64{
+		"tl": &radii.TopLeft,
+		"tr": &radii.TopRight,
+		"br": &radii.BottomRight,
+		"bl": &radii.BottomLeft,
+	}
+
+	for name, target := range corners {
+		if v, ok := params[name]; ok {
+			val, err := strconv.ParseFloat(v, 64)
+			if err != nil || val < 0 {
+				return radii, fmt.Errorf("invalid corner radius (%s) value '%s'", name, v)
+			}
+			*target = val
+		}
+	}
+
+	return radii, nil
+}
+
+// Execute runs the round manipulator and rounds the corners of the image
+func (manipulator *RoundManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	radii, err := parseRadii(params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := toNRGBA(img)
+	w := float64(result.Bounds().Dx())
+	h := float64(result.Bounds().Dy())
+	radii = radii.clamp(w, h)
+
+	applyCoverage(result, func(px, py float64) float64 {
+		return roundedRectCoverage(px, py, w, h, radii)
+	})
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *RoundManipulator) Schema() Schema {
+	return Schema{
+		Description: "Rounds the corners of the image",
+		Params: []ParamSchema{
+			Param("r", ParamString, "radius in pixels for all corners, or tl|tr|br|bl"),
+			Param("tl", ParamFloat, "top left corner radius in pixels").Range(0, 20000),
+			Param("tr", ParamFloat, "top right corner radius in pixels").Range(0, 20000),
+			Param("br", ParamFloat, "bottom right corner radius in pixels").Range(0, 20000),
+			Param("bl", ParamFloat, "bottom left corner radius in pixels").Range(0, 20000),
+		},
+	}
+}
+
+// NewRoundManipulator returns a new round corners Manipulator
+func NewRoundManipulator(cfg *config.Config) *RoundManipulator {
+	return &RoundManipulator{}
+}
@@ -0,0 +1,155 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newSolidImage(width, height int, col color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, col)
+		}
+	}
+	return img
+}
+
+func alphaAt(img image.Image, x, y int) uint32 {
+	_, _, _, a := img.At(x, y).RGBA()
+	return a >> 8
+}
+
+func TestRoundManipulator(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewRoundManipulator(cfg)
+	testImg := newSolidImage(40, 40, color.RGBA{255, 0, 0, 255})
+
+	tests := []struct {
+		name        string
+		params      map[string]string
+		transparent []image.Point
+		opaque      []image.Point
+	}{
+		{
+			name:        "same radius for all corners",
+			params:      map[string]string{"r": "10"},
+			transparent: []image.Point{{0, 0}, {39, 0}, {39, 39}, {0, 39}},
+			opaque:      []image.Point{{20, 20}, {10, 0}, {0, 10}},
+		},
+		{
+			name:        "per corner radii",
+			params:      map[string]string{"r": "10|0|0|0"},
+			transparent: []image.Point{{0, 0}},
+			opaque:      []image.Point{{39, 0}, {39, 39}, {0, 39}},
+		},
+		{
+			name:        "single corner override",
+			params:      map[string]string{"br": "20"},
+			transparent: []image.Point{{39, 39}},
+			opaque:      []image.Point{{0, 0}, {39, 0}, {0, 39}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *fiber.Ctx
+
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			for _, p := range tt.transparent {
+				if a := alphaAt(result, p.X, p.Y); a != 0 {
+					t.Errorf("expected transparent pixel at %v, got alpha %d", p, a)
+				}
+			}
+
+			for _, p := range tt.opaque {
+				if a := alphaAt(result, p.X, p.Y); a != 255 {
+					t.Errorf("expected opaque pixel at %v, got alpha %d", p, a)
+				}
+			}
+		})
+	}
+
+	if _, err := manipulator.Execute(nil, map[string]string{"r": "1|2"}, testImg); err == nil {
+		t.Error("Expected error for 2 radius values")
+	}
+}
+
+func TestCircleManipulator(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewCircleManipulator(cfg)
+	testImg := newSolidImage(40, 40, color.RGBA{255, 0, 0, 255})
+
+	result, err := manipulator.Execute(nil, map[string]string{}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if a := alphaAt(result, 0, 0); a != 0 {
+		t.Errorf("expected transparent corner, got alpha %d", a)
+	}
+
+	if a := alphaAt(result, 20, 20); a != 255 {
+		t.Errorf("expected opaque center, got alpha %d", a)
+	}
+
+	// Edge pixels should be partially covered (anti-aliased)
+	partial := false
+	for x := 0; x < 20; x++ {
+		if a := alphaAt(result, x, 20-x/2); a > 0 && a < 255 {
+			partial = true
+			break
+		}
+	}
+	if !partial {
+		t.Error("expected anti-aliased edge pixels")
+	}
+}
+
+func TestBorderManipulator(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewBorderManipulator(cfg)
+	testImg := newSolidImage(20, 20, color.RGBA{255, 0, 0, 255})
+
+	result, err := manipulator.Execute(nil, map[string]string{"w": "5", "c": "00ff00"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if result.Bounds() != image.Rect(0, 0, 30, 30) {
+		t.Fatalf("expected 30x30 image, got %v", result.Bounds())
+	}
+
+	if r, g, _, _ := result.At(2, 2).RGBA(); r != 0 || g != 0xffff {
+		t.Error("expected border color at (2, 2)")
+	}
+
+	if r, g, _, _ := result.At(15, 15).RGBA(); r != 0xffff || g != 0 {
+		t.Error("expected image color at (15, 15)")
+	}
+
+	result, err = manipulator.Execute(nil, map[string]string{"w": "5", "r": "10"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if a := alphaAt(result, 0, 0); a != 0 {
+		t.Errorf("expected transparent outer corner, got alpha %d", a)
+	}
+
+	if _, err := manipulator.Execute(nil, map[string]string{"w": "5", "c": "nothex"}, testImg); err == nil {
+		t.Error("Expected error for invalid color")
+	}
+
+	if _, err := manipulator.Execute(nil, map[string]string{"w": "5000"}, testImg); err == nil {
+		t.Error("Expected error for a canvas larger than the max image dimension")
+	}
+}
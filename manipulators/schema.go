@@ -0,0 +1,202 @@
+package manipulators
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParamType is the type of the value of a manipulator parameter
+type ParamType string
+
+const (
+	// ParamInt is a whole number
+	ParamInt ParamType = "int"
+	// ParamFloat is a number
+	ParamFloat ParamType = "float"
+	// ParamPercent is a number with an optional '%' suffix
+	ParamPercent ParamType = "percent"
+	// ParamLength is a number of pixels or a percentage of the image size (e.g. 10%)
+	ParamLength ParamType = "length"
+	// ParamBool is a boolean flag - 0/1 (true/false are accepted as well and normalized to 0/1)
+	ParamBool ParamType = "bool"
+	// ParamEnum is one of a fixed list of values
+	ParamEnum ParamType = "enum"
+	// ParamColor is a hex value (RGB, RRGGBB or RRGGBBAA) or a color name
+	ParamColor ParamType = "color"
+	// ParamString is a free form value validated by the manipulator itself
+	ParamString ParamType = "string"
+)
+
+// ParamSchema describes a single manipulator parameter
+type ParamSchema struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Min         *float64  `json:"min,omitempty"`
+	Max         *float64  `json:"max,omitempty"`
+	Enum        []string  `json:"enum,omitempty"`
+	Default     string    `json:"default,omitempty"`
+	Required    bool      `json:"required,omitempty"`
+}
+
+// Schema describes a manipulator and the parameters it accepts
+type Schema struct {
+	Description string        `json:"description"`
+	Params      []ParamSchema `json:"params"`
+}
+
+// Param returns a parameter of the given name and type
+func Param(name string, paramType ParamType, description string) ParamSchema {
+	return ParamSchema{Name: name, Type: paramType, Description: description}
+}
+
+// EnumParam returns an enum parameter accepting the given values
+func EnumParam(name string, description string, values ...string) ParamSchema {
+	return ParamSchema{Name: name, Type: ParamEnum, Description: description, Enum: values}
+}
+
+// Range returns a copy of the parameter limited to the min-max range
+func (p ParamSchema) Range(min, max float64) ParamSchema {
+	p.Min = &min
+	p.Max = &max
+	return p
+}
+
+// AtLeast returns a copy of the parameter limited to values of at least min
+func (p ParamSchema) AtLeast(min float64) ParamSchema {
+	p.Min = &min
+	return p
+}
+
+// WithDefault returns a copy of the parameter with the default value used when it is missing
+func (p ParamSchema) WithDefault(value string) ParamSchema {
+	p.Default = value
+	return p
+}
+
+// AsRequired returns a copy of the parameter marked as required
+func (p ParamSchema) AsRequired() ParamSchema {
+	p.Required = true
+	return p
+}
+
+// Validate checks that a value matches the type and limits of the parameter
+func (p ParamSchema) Validate(value string) error {
+	var number float64
+	var err error
+
+	switch p.Type {
+	case ParamInt:
+		var i int
+		if i, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("parameter %s requires a whole number, got: %s", p.Name, value)
+		}
+		number = float64(i)
+	case ParamFloat:
+		if number, err = strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("parameter %s requires a numeric value, got: %s", p.Name, value)
+		}
+	case ParamPercent, ParamLength:
+		if number, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err != nil {
+			return fmt.Errorf("parameter %s requires a numeric value or a percentage, got: %s", p.Name, value)
+		}
+	case ParamBool:
+		if value != "0" && value != "1" && value != "true" && value != "false" {
+			return fmt.Errorf("parameter %s requires a boolean value (0/1), got: %s", p.Name, value)
+		}
+		return nil
+	case ParamEnum:
+		for _, v := range p.Enum {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("parameter %s must be one of %s, got: %s", p.Name, strings.Join(p.Enum, ", "), value)
+	case ParamColor:
+		if _, err = parseColor(value); err != nil {
+			return fmt.Errorf("parameter %s: %v", p.Name, err)
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	// NaN fails every comparison, so it would pass any range
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return fmt.Errorf("parameter %s requires a finite number, got: %s", p.Name, value)
+	}
+
+	if (p.Min != nil && number < *p.Min) || (p.Max != nil && number > *p.Max) {
+		return fmt.Errorf("parameter %s value %g is outside valid range [%s, %s]", p.Name, number, formatBound(p.Min), formatBound(p.Max))
+	}
+
+	return nil
+}
+
+// formatBound formats an optional range bound for error messages
+func formatBound(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*v, 'g', -1, 64)
+}
+
+// Validate checks the parameters of a step against the schema, rejecting unknown parameters and missing
+// required ones
+func (s Schema) Validate(params map[string]string) error {
+	known := make(map[string]ParamSchema, len(s.Params))
+	for _, p := range s.Params {
+		known[p.Name] = p
+		if _, ok := params[p.Name]; p.Required && !ok {
+			return fmt.Errorf("missing required parameter %s", p.Name)
+		}
+	}
+
+	// Validate in a stable order so the same request always reports the same error
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p, ok := known[name]
+		if !ok {
+			return fmt.Errorf("unknown parameter %s", name)
+		}
+		if err := p.Validate(params[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Normalize rewrites the values of boolean parameters given as true/false to 0/1, the only form manipulators
+// check for. It should be called on parameters that passed Validate
+func (s Schema) Normalize(params map[string]string) {
+	for _, p := range s.Params {
+		if p.Type != ParamBool {
+			continue
+		}
+
+		switch params[p.Name] {
+		case "true":
+			params[p.Name] = "1"
+		case "false":
+			params[p.Name] = "0"
+		}
+	}
+}
+
+// GetSchemas returns the schemas of all the registered manipulators by name
+func GetSchemas() map[string]Schema {
+	schemas := make(map[string]Schema, len(manipulatorsRegistry))
+	for name, manipulator := range manipulatorsRegistry {
+		schemas[name] = manipulator.Schema()
+	}
+	return schemas
+}
@@ -0,0 +1,137 @@
+package manipulators
+
+import (
+	"testing"
+
+	"github.com/erans/thumbla/config"
+)
+
+func TestParamSchema_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   ParamSchema
+		value   string
+		wantErr bool
+	}{
+		{"int", Param("w", ParamInt, "").Range(1, 100), "50", false},
+		{"int not a whole number", Param("w", ParamInt, ""), "5.5", true},
+		{"int below range", Param("w", ParamInt, "").Range(1, 100), "0", true},
+		{"int above range", Param("w", ParamInt, "").Range(1, 100), "101", true},
+		{"float", Param("a", ParamFloat, "").Range(-360, 360), "-45.5", false},
+		{"float not a number", Param("a", ParamFloat, ""), "abc", true},
+		{"float at least", Param("s", ParamFloat, "").AtLeast(0), "-1", true},
+		{"float NaN", Param("a", ParamFloat, "").Range(-360, 360), "NaN", true},
+		{"float infinity", Param("s", ParamFloat, "").AtLeast(0), "Inf", true},
+		{"float overflow", Param("s", ParamFloat, "").AtLeast(0), "1e400", true},
+		{"percent with suffix", Param("scale", ParamPercent, "").AtLeast(0), "50%", false},
+		{"percent without suffix", Param("scale", ParamPercent, ""), "50", false},
+		{"percent NaN", Param("scale", ParamPercent, "").Range(0, 1000), "NaN%", true},
+		{"length in pixels", Param("p", ParamLength, "").AtLeast(0), "10", false},
+		{"length as percentage", Param("p", ParamLength, "").AtLeast(0), "10%", false},
+		{"negative length", Param("p", ParamLength, "").AtLeast(0), "-10%", true},
+		{"length infinity", Param("p", ParamLength, "").AtLeast(0), "+Inf", true},
+		{"bool", Param("r", ParamBool, ""), "1", false},
+		{"bool true", Param("r", ParamBool, ""), "true", false},
+		{"invalid bool", Param("r", ParamBool, ""), "yes", true},
+		{"enum", EnumParam("r", "", "linear", "lanczos"), "lanczos", false},
+		{"unknown enum value", EnumParam("r", "", "linear", "lanczos"), "cubic", true},
+		{"hex color", Param("bg", ParamColor, ""), "ff0000", false},
+		{"named color", Param("bg", ParamColor, ""), "transparent", false},
+		{"invalid color", Param("bg", ParamColor, ""), "nocolor", true},
+		{"string", Param("r", ParamString, ""), "0|0|10|10", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.param.Validate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema := Schema{Params: []ParamSchema{
+		Param("name", ParamString, "").AsRequired(),
+		Param("w", ParamInt, "").Range(1, 100),
+	}}
+
+	tests := []struct {
+		name    string
+		params  map[string]string
+		wantErr bool
+	}{
+		{"valid", map[string]string{"name": "a", "w": "10"}, false},
+		{"optional missing", map[string]string{"name": "a"}, false},
+		{"required missing", map[string]string{"w": "10"}, true},
+		{"unknown parameter", map[string]string{"name": "a", "x": "1"}, true},
+		{"invalid value", map[string]string{"name": "a", "w": "1000"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%v) error = %v, wantErr %v", tt.params, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchema_Normalize(t *testing.T) {
+	schema := Schema{Params: []ParamSchema{
+		Param("fit", ParamBool, ""),
+		Param("upscale", ParamBool, ""),
+		Param("tile", ParamBool, ""),
+		Param("name", ParamString, ""),
+	}}
+
+	params := map[string]string{"fit": "false", "upscale": "true", "tile": "1", "name": "true"}
+	schema.Normalize(params)
+
+	expected := map[string]string{"fit": "0", "upscale": "1", "tile": "1", "name": "true"}
+	for name, value := range expected {
+		if params[name] != value {
+			t.Errorf("%s = %q, want %q", name, params[name], value)
+		}
+	}
+}
+
+func TestGetSchemas(t *testing.T) {
+	InitManipulators(&config.Config{})
+
+	schemas := GetSchemas()
+	if len(schemas) != len(manipulatorsRegistry) {
+		t.Fatalf("expected a schema for each of the %d manipulators, got %d", len(manipulatorsRegistry), len(schemas))
+	}
+
+	for name, schema := range schemas {
+		if schema.Description == "" {
+			t.Errorf("%s: missing description", name)
+		}
+
+		seen := map[string]bool{}
+		for _, p := range schema.Params {
+			if seen[p.Name] {
+				t.Errorf("%s: parameter %s is declared twice", name, p.Name)
+			}
+			seen[p.Name] = true
+
+			// Defaults must be valid values of their own parameter
+			if p.Default != "" {
+				if err := p.Validate(p.Default); err != nil {
+					t.Errorf("%s: invalid default of %s: %v", name, p.Name, err)
+				}
+			}
+		}
+	}
+
+	// Parameters that share a name across manipulators must be validated by each manipulator's own rules
+	if err := schemas["resize"].Validate(map[string]string{"w": "100", "r": "lanczos"}); err != nil {
+		t.Errorf("resize: unexpected error %v", err)
+	}
+	if err := schemas["crop"].Validate(map[string]string{"r": "0|0|50|-10"}); err != nil {
+		t.Errorf("crop: unexpected error %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"github.com/anthonynsimon/bild/blur"
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Limit the blur radius and offsets as the canvas grows with them
+const (
+	maxShadowBlur   = 500
+	maxShadowOffset = 500
+)
+
+// ShadowManipulator renders a drop shadow derived from the alpha channel of the image, extending the canvas
+// to fit the shadow
+//
+// Supported parameters:
+// - x, y (int) - shadow offset in pixels between -500 and 500 (default 5, 5)
+// - blur (float) - shadow blur radius in pixels (default 10)
+// - c (color) - shadow color (default black)
+// - opacity (float 0-100) - shadow opacity in percentages (default 50)
+type ShadowManipulator struct {
+	Cfg *config.Config
+}
+
+// Execute runs the shadow manipulator
+func (manipulator *ShadowManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var err error
+	var offset = image.Pt(5, 5)
+	offsets := map[string]*int{"x": &offset.X, "y": &offset.Y}
+	for name, target := range offsets {
+		if v, ok := params[name]; ok {
+			if *target, err = strconv.Atoi(v); err != nil || *target < -maxShadowOffset || *target > maxShadowOffset {
+				return nil, fmt.Errorf("shadow offset (%s) must be a value between -%d and %d", name, maxShadowOffset, maxShadowOffset)
+			}
+		}
+	}
+
+	var radius = 10.0
+	if v, ok := params["blur"]; ok {
+		if radius, err = strconv.ParseFloat(v, 64); err != nil || radius < 0 || radius > maxShadowBlur {
+			return nil, fmt.Errorf("shadow blur must be a value between 0 and %d", maxShadowBlur)
+		}
+	}
+
+	var shadowColor = color.NRGBA{0, 0, 0, 255}
+	if v, ok := params["c"]; ok {
+		if shadowColor, err = parseColor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	opacity, err := parsePercentageParam(params, "opacity", 50)
+	if err != nil {
+		return nil, err
+	}
+
+	src := toNRGBA(img)
+	imageRect := src.Bounds()
+	margin := int(math.Ceil(radius))
+	shadowRect := imageRect.Add(offset).Inset(-margin)
+
+	// Move the union of the image and its shadow to the origin
+	canvasRect := imageRect.Union(shadowRect)
+	imageRect = imageRect.Sub(canvasRect.Min)
+	shadowRect = shadowRect.Sub(canvasRect.Min)
+	canvasRect = canvasRect.Sub(canvasRect.Min)
+	if err := checkCanvasSize(manipulator.Cfg, canvasRect.Size()); err != nil {
+		return nil, err
+	}
+
+	shadow := image.NewNRGBA(canvasRect)
+	alphaScale := float64(shadowColor.A) / 255 * opacity
+	for y := 0; y < src.Bounds().Dy(); y++ {
+		for x := 0; x < src.Bounds().Dx(); x++ {
+			a := src.Pix[src.PixOffset(x, y)+3]
+			if a == 0 {
+				continue
+			}
+			i := shadow.PixOffset(imageRect.Min.X+offset.X+x, imageRect.Min.Y+offset.Y+y)
+			shadow.Pix[i] = shadowColor.R
+			shadow.Pix[i+1] = shadowColor.G
+			shadow.Pix[i+2] = shadowColor.B
+			shadow.Pix[i+3] = clampChannel(float64(a) * alphaScale)
+		}
+	}
+
+	canvas := image.NewNRGBA(canvasRect)
+	draw.Draw(canvas, canvasRect, blur.Gaussian(shadow, radius), image.Point{}, draw.Src)
+	draw.Draw(canvas, imageRect, src, image.Point{}, draw.Over)
+
+	return canvas, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *ShadowManipulator) Schema() Schema {
+	return Schema{
+		Description: "Renders a drop shadow",
+		Params: []ParamSchema{
+			Param("x", ParamInt, "horizontal offset in pixels").Range(-maxShadowOffset, maxShadowOffset).WithDefault("5"),
+			Param("y", ParamInt, "vertical offset in pixels").Range(-maxShadowOffset, maxShadowOffset).WithDefault("5"),
+			Param("blur", ParamFloat, "blur radius in pixels").Range(0, maxShadowBlur).WithDefault("10"),
+			Param("c", ParamColor, "shadow color").WithDefault("black"),
+			Param("opacity", ParamFloat, "opacity in percentages").Range(0, 100).WithDefault("50"),
+		},
+	}
+}
+
+// NewShadowManipulator returns a new shadow Manipulator
+func NewShadowManipulator(cfg *config.Config) *ShadowManipulator {
+	return &ShadowManipulator{Cfg: cfg}
+}
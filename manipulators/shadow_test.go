@@ -0,0 +1,84 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestShadowManipulator(t *testing.T) {
+	manipulator := NewShadowManipulator(&config.Config{})
+	testImg := newSolidImage(20, 10, color.RGBA{255, 0, 0, 255})
+
+	var c *fiber.Ctx
+	tests := []struct {
+		name       string
+		params     map[string]string
+		wantBounds image.Rectangle
+		imageAt    image.Point
+		wantErr    bool
+	}{
+		{"defaults", map[string]string{}, image.Rect(0, 0, 40, 30), image.Pt(5, 5), false},
+		{"no blur", map[string]string{"x": "4", "y": "2", "blur": "0"}, image.Rect(0, 0, 24, 12), image.Pt(0, 0), false},
+		{"negative offset", map[string]string{"x": "-4", "y": "0", "blur": "2"}, image.Rect(0, 0, 26, 14), image.Pt(6, 2), false},
+		{"invalid blur", map[string]string{"blur": "-1"}, image.Rectangle{}, image.Point{}, true},
+		{"invalid opacity", map[string]string{"opacity": "101"}, image.Rectangle{}, image.Point{}, true},
+		{"offset out of range", map[string]string{"x": "100000000"}, image.Rectangle{}, image.Point{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if result.Bounds() != tt.wantBounds {
+				t.Errorf("bounds = %v, want %v", result.Bounds(), tt.wantBounds)
+			}
+
+			if r, _, _, a := result.At(tt.imageAt.X, tt.imageAt.Y).RGBA(); r>>8 != 255 || a>>8 != 255 {
+				t.Errorf("expected the image to be drawn at %v", tt.imageAt)
+			}
+		})
+	}
+
+	// The shadow without blur is a solid half transparent black below and to the right of the image
+	result, _ := manipulator.Execute(c, map[string]string{"x": "4", "y": "2", "blur": "0"}, testImg)
+	if a := alphaAt(result, 22, 11); a < 120 || a > 135 {
+		t.Errorf("expected a half transparent shadow, got alpha %d", a)
+	}
+	if a := alphaAt(result, 22, 0); a != 0 {
+		t.Errorf("expected no shadow outside of the offset shape, got alpha %d", a)
+	}
+}
+
+func TestVignetteManipulator(t *testing.T) {
+	manipulator := NewVignetteManipulator(&config.Config{})
+	testImg := newSolidImage(40, 20, color.RGBA{200, 200, 200, 255})
+
+	var c *fiber.Ctx
+	result, err := manipulator.Execute(c, map[string]string{"strength": "80", "radius": "30"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	center, _, _, _ := result.At(20, 10).RGBA()
+	corner, _, _, _ := result.At(0, 0).RGBA()
+	if center>>8 != 200 {
+		t.Errorf("expected the center to be untouched, got %d", center>>8)
+	}
+	if corner>>8 >= 100 {
+		t.Errorf("expected the corner to be darkened, got %d", corner>>8)
+	}
+
+	if _, err := manipulator.Execute(c, map[string]string{"radius": "abc"}, testImg); err == nil {
+		t.Errorf("expected an error for an invalid radius")
+	}
+}
@@ -24,6 +24,16 @@ func (manipulator *ShearHorizontalManipulator) Execute(c *fiber.Ctx, params map[
 	return img, nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *ShearHorizontalManipulator) Schema() Schema {
+	return Schema{
+		Description: "Shears the image horizontally",
+		Params: []ParamSchema{
+			Param("a", ParamFloat, "angle in degrees").Range(-360, 360),
+		},
+	}
+}
+
 // NewShearHorizontalManipulator returns a new shear horizontal Manipulator
 func NewShearHorizontalManipulator(cfg *config.Config) *ShearHorizontalManipulator {
 	return &ShearHorizontalManipulator{}
@@ -24,6 +24,16 @@ func (manipulator *ShearVerticalManipulator) Execute(c *fiber.Ctx, params map[st
 	return img, nil
 }
 
+// Schema describes the parameters of the manipulator
+func (manipulator *ShearVerticalManipulator) Schema() Schema {
+	return Schema{
+		Description: "Shears the image vertically",
+		Params: []ParamSchema{
+			Param("a", ParamFloat, "angle in degrees").Range(-360, 360),
+		},
+	}
+}
+
 // NewShearVerticalManipulator returns a new shear vertical Manipulator
 func NewShearVerticalManipulator(cfg *config.Config) *ShearVerticalManipulator {
 	return &ShearVerticalManipulator{}
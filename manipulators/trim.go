@@ -0,0 +1,119 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TrimManipulator crops uniform borders around the image
+//
+// Supported parameters:
+// - bg (color) - background color to trim. Defaults to the color of the top left pixel
+// - tol (float 0-100) - color tolerance in percentages (default 0 - exact match)
+// - p (int) - padding in pixels to keep around the detected content
+type TrimManipulator struct {
+}
+
+// isBackground returns true if the pixel matches the background color within the tolerance (0-1)
+func isBackground(pixel color.NRGBA, bg color.NRGBA, tolerance float64) bool {
+	// RGB values of fully transparent pixels are meaningless
+	if pixel.A == 0 && bg.A == 0 {
+		return true
+	}
+
+	diff := math.Max(
+		math.Max(math.Abs(float64(pixel.R)-float64(bg.R)), math.Abs(float64(pixel.G)-float64(bg.G))),
+		math.Max(math.Abs(float64(pixel.B)-float64(bg.B)), math.Abs(float64(pixel.A)-float64(bg.A))),
+	)
+
+	return diff/255 <= tolerance
+}
+
+// Execute runs the trim manipulator and crops the image to its content
+func (manipulator *TrimManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	var err error
+	var tolerance = 0.0
+	if v, ok := params["tol"]; ok {
+		if tolerance, err = strconv.ParseFloat(v, 64); err != nil || tolerance < 0 || tolerance > 100 {
+			return nil, fmt.Errorf("tolerance (tol) must be a value between 0 and 100")
+		}
+	}
+	tolerance /= 100
+
+	var padding = 0
+	if v, ok := params["p"]; ok {
+		if padding, err = strconv.Atoi(v); err != nil || padding < 0 {
+			return nil, fmt.Errorf("invalid padding (p) value")
+		}
+	}
+
+	src := toNRGBA(img)
+	b := src.Bounds()
+	if b.Empty() {
+		return img, nil
+	}
+
+	bg := src.NRGBAAt(0, 0)
+	if v, ok := params["bg"]; ok {
+		if bg, err = parseColor(v); err != nil {
+			return nil, err
+		}
+	}
+
+	content := image.Rectangle{Min: b.Max, Max: b.Min}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if isBackground(src.NRGBAAt(x, y), bg, tolerance) {
+				continue
+			}
+
+			if x < content.Min.X {
+				content.Min.X = x
+			}
+			if y < content.Min.Y {
+				content.Min.Y = y
+			}
+			if x+1 > content.Max.X {
+				content.Max.X = x + 1
+			}
+			if y+1 > content.Max.Y {
+				content.Max.Y = y + 1
+			}
+		}
+	}
+
+	// The whole image is background, nothing to trim to
+	if content.Empty() {
+		return img, nil
+	}
+
+	content = content.Inset(-padding).Intersect(b)
+	if content == b {
+		return img, nil
+	}
+
+	return toNRGBA(src.SubImage(content)), nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *TrimManipulator) Schema() Schema {
+	return Schema{
+		Description: "Crops uniform borders around the image",
+		Params: []ParamSchema{
+			Param("bg", ParamColor, "background color to trim, defaults to the top left pixel"),
+			Param("tol", ParamFloat, "color tolerance in percentages").Range(0, 100).WithDefault("0"),
+			Param("p", ParamInt, "padding in pixels to keep around the content").Range(0, 20000),
+		},
+	}
+}
+
+// NewTrimManipulator returns a new trim Manipulator
+func NewTrimManipulator(cfg *config.Config) *TrimManipulator {
+	return &TrimManipulator{}
+}
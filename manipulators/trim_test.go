@@ -0,0 +1,85 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTrimManipulator(t *testing.T) {
+	cfg := &config.Config{}
+	manipulator := NewTrimManipulator(cfg)
+
+	// 20x20 white image with a red 6x4 product at (8, 5) and a slightly off-white pixel at (1, 1)
+	testImg := newSolidImage(20, 20, color.RGBA{255, 255, 255, 255})
+	for y := 5; y < 9; y++ {
+		for x := 8; x < 14; x++ {
+			testImg.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	testImg.Set(1, 1, color.RGBA{250, 250, 250, 255})
+
+	// Transparent image with a single opaque pixel
+	transparentImg := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	transparentImg.Set(4, 6, color.NRGBA{0, 255, 0, 255})
+
+	tests := []struct {
+		name     string
+		params   map[string]string
+		img      image.Image
+		expected image.Rectangle
+	}{
+		{
+			name:     "exact match keeps off-white pixel",
+			params:   map[string]string{},
+			img:      testImg,
+			expected: image.Rect(0, 0, 13, 8),
+		},
+		{
+			name:     "tolerance ignores off-white pixel",
+			params:   map[string]string{"tol": "5"},
+			img:      testImg,
+			expected: image.Rect(0, 0, 6, 4),
+		},
+		{
+			name:     "padding",
+			params:   map[string]string{"tol": "5", "p": "2"},
+			img:      testImg,
+			expected: image.Rect(0, 0, 10, 8),
+		},
+		{
+			name:     "transparent background",
+			params:   map[string]string{},
+			img:      transparentImg,
+			expected: image.Rect(0, 0, 1, 1),
+		},
+		{
+			name:     "explicit background that does not match",
+			params:   map[string]string{"bg": "000"},
+			img:      testImg,
+			expected: image.Rect(0, 0, 20, 20),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *fiber.Ctx
+
+			result, err := manipulator.Execute(c, tt.params, tt.img)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if result.Bounds() != tt.expected {
+				t.Errorf("Execute() bounds = %v, expected %v", result.Bounds(), tt.expected)
+			}
+		})
+	}
+
+	if _, err := manipulator.Execute(nil, map[string]string{"tol": "200"}, testImg); err == nil {
+		t.Error("Expected error for invalid tolerance")
+	}
+}
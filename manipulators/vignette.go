@@ -0,0 +1,82 @@
+package manipulators
+
+import (
+	"image"
+	"math"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// VignetteManipulator darkens the image towards its edges
+//
+// Supported parameters:
+// - strength (float 0-100) - how much the corners are darkened in percentages (default 50)
+// - radius (float 0-100) - distance from the center, in percentages of the distance to the corners, at which the darkening starts (default 50)
+type VignetteManipulator struct {
+}
+
+// Execute runs the vignette manipulator
+func (manipulator *VignetteManipulator) Execute(c *fiber.Ctx, params map[string]string, img image.Image) (image.Image, error) {
+	strength, err := parsePercentageParam(params, "strength", 50)
+	if err != nil {
+		return nil, err
+	}
+
+	radius, err := parsePercentageParam(params, "radius", 50)
+	if err != nil {
+		return nil, err
+	}
+
+	result := toNRGBA(img)
+	if strength == 0 {
+		return result, nil
+	}
+
+	b := result.Bounds()
+	cx := float64(b.Dx()) / 2
+	cy := float64(b.Dy()) / 2
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			// Elliptical distance from the center, normalized so that the corners are at 1
+			dx := (float64(x-b.Min.X) + 0.5 - cx) / cx
+			dy := (float64(y-b.Min.Y) + 0.5 - cy) / cy
+			d := math.Sqrt(dx*dx+dy*dy) / math.Sqrt2
+			if d <= radius {
+				continue
+			}
+
+			t := 1.0
+			if radius < 1 {
+				t = math.Min(1, (d-radius)/(1-radius))
+			}
+			// Smoothstep for a gradual falloff
+			factor := 1 - strength*t*t*(3-2*t)
+
+			i := result.PixOffset(x, y)
+			px := result.Pix[i : i+3 : i+3]
+			for ch := range px {
+				px[ch] = clampChannel(float64(px[ch]) * factor)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Schema describes the parameters of the manipulator
+func (manipulator *VignetteManipulator) Schema() Schema {
+	return Schema{
+		Description: "Darkens the image towards its edges",
+		Params: []ParamSchema{
+			Param("strength", ParamFloat, "darkening of the corners in percentages").Range(0, 100).WithDefault("50"),
+			Param("radius", ParamFloat, "distance from the center at which the darkening starts in percentages").Range(0, 100).WithDefault("50"),
+		},
+	}
+}
+
+// NewVignetteManipulator returns a new vignette Manipulator
+func NewVignetteManipulator(cfg *config.Config) *VignetteManipulator {
+	return &VignetteManipulator{}
+}
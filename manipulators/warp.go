@@ -0,0 +1,143 @@
+package manipulators
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	interpolationNearest  = "nearest"
+	interpolationBilinear = "bilinear"
+	interpolationBicubic  = "bicubic"
+)
+
+// parseInterpolation parses the optional interp parameter (default bilinear)
+func parseInterpolation(params map[string]string) (string, error) {
+	v, ok := params["interp"]
+	if !ok {
+		return interpolationBilinear, nil
+	}
+
+	switch v {
+	case interpolationNearest, interpolationBilinear, interpolationBicubic:
+		return v, nil
+	}
+
+	return "", fmt.Errorf("unknown interpolation '%s'", v)
+}
+
+// interpolationParam describes the interp parameter parsed by parseInterpolation
+func interpolationParam() ParamSchema {
+	return EnumParam("interp", "interpolation", interpolationNearest, interpolationBilinear, interpolationBicubic).WithDefault(interpolationBilinear)
+}
+
+// parseFloats parses a list of count numbers separated by a '|' sign
+func parseFloats(name, value string, count int) ([]float64, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) != count {
+		return nil, fmt.Errorf("%s must have %d values separated by a '|' sign", name, count)
+	}
+
+	values := make([]float64, count)
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid %s value '%s'", name, p)
+		}
+		values[i] = v
+	}
+
+	return values, nil
+}
+
+// cubicWeight returns the Catmull-Rom kernel weight for the given distance
+func cubicWeight(d float64) float64 {
+	d = math.Abs(d)
+	switch {
+	case d < 1:
+		return 1.5*d*d*d - 2.5*d*d + 1
+	case d < 2:
+		return -0.5*d*d*d + 2.5*d*d - 4*d + 2
+	}
+	return 0
+}
+
+// warpImage renders an image of the given size where every destination pixel center is mapped through inverse
+// to a position in the source image. Positions outside of the source are filled with the background color
+func warpImage(src *image.NRGBA, size image.Point, inverse func(x, y float64) (float64, float64), interpolation string, bg color.NRGBA) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+
+	bgA := float64(bg.A) / 255
+	bgPremultiplied := [4]float64{float64(bg.R) * bgA, float64(bg.G) * bgA, float64(bg.B) * bgA, float64(bg.A)}
+
+	// tap returns the premultiplied color of a source pixel, or the background outside of the source
+	tap := func(x, y int) [4]float64 {
+		if x < b.Min.X || y < b.Min.Y || x >= b.Max.X || y >= b.Max.Y {
+			return bgPremultiplied
+		}
+		i := src.PixOffset(x, y)
+		a := float64(src.Pix[i+3]) / 255
+		return [4]float64{float64(src.Pix[i]) * a, float64(src.Pix[i+1]) * a, float64(src.Pix[i+2]) * a, float64(src.Pix[i+3])}
+	}
+
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			sx, sy := inverse(float64(x)+0.5, float64(y)+0.5)
+			if math.IsNaN(sx) || math.IsNaN(sy) || math.IsInf(sx, 0) || math.IsInf(sy, 0) {
+				continue
+			}
+			// Move from pixel centers to pixel indexes
+			sx -= 0.5
+			sy -= 0.5
+
+			var sum [4]float64
+			switch interpolation {
+			case interpolationNearest:
+				sum = tap(int(math.Round(sx)), int(math.Round(sy)))
+			case interpolationBicubic:
+				x0 := int(math.Floor(sx))
+				y0 := int(math.Floor(sy))
+				for j := -1; j <= 2; j++ {
+					wy := cubicWeight(sy - float64(y0+j))
+					for i := -1; i <= 2; i++ {
+						w := wy * cubicWeight(sx-float64(x0+i))
+						t := tap(x0+i, y0+j)
+						for ch := range sum {
+							sum[ch] += t[ch] * w
+						}
+					}
+				}
+			default:
+				x0 := int(math.Floor(sx))
+				y0 := int(math.Floor(sy))
+				fx := sx - float64(x0)
+				fy := sy - float64(y0)
+				taps := [4][4]float64{tap(x0, y0), tap(x0+1, y0), tap(x0, y0+1), tap(x0+1, y0+1)}
+				weights := [4]float64{(1 - fx) * (1 - fy), fx * (1 - fy), (1 - fx) * fy, fx * fy}
+				for k, t := range taps {
+					for ch := range sum {
+						sum[ch] += t[ch] * weights[k]
+					}
+				}
+			}
+
+			a := math.Max(0, math.Min(255, sum[3]))
+			if a == 0 {
+				continue
+			}
+
+			i := dst.PixOffset(x, y)
+			for ch := 0; ch < 3; ch++ {
+				dst.Pix[i+ch] = clampChannel(sum[ch] * 255 / a)
+			}
+			dst.Pix[i+3] = clampChannel(a)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,116 @@
+package manipulators
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/erans/thumbla/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newQuadrantsImage returns an image with a red top-left, green top-right, blue bottom-left and white bottom-right quadrant
+func newQuadrantsImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			switch {
+			case x < width/2 && y < height/2:
+				img.Set(x, y, color.NRGBA{255, 0, 0, 255})
+			case y < height/2:
+				img.Set(x, y, color.NRGBA{0, 255, 0, 255})
+			case x < width/2:
+				img.Set(x, y, color.NRGBA{0, 0, 255, 255})
+			default:
+				img.Set(x, y, color.NRGBA{255, 255, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestAffineManipulator(t *testing.T) {
+	manipulator := NewAffineManipulator(&config.Config{})
+	testImg := newQuadrantsImage(20, 10)
+
+	var c *fiber.Ctx
+	tests := []struct {
+		name       string
+		params     map[string]string
+		wantBounds image.Rectangle
+		// Color expected at the top-left corner of the result
+		wantCorner color.NRGBA
+		wantErr    bool
+	}{
+		{"identity", map[string]string{"m": "1|0|0|0|1|0"}, image.Rect(0, 0, 20, 10), color.NRGBA{255, 0, 0, 255}, false},
+		{"scale", map[string]string{"m": "2|0|0|0|0.5|0", "interp": "nearest"}, image.Rect(0, 0, 40, 5), color.NRGBA{255, 0, 0, 255}, false},
+		{"mirror", map[string]string{"m": "-1|0|0|0|1|0", "interp": "bicubic"}, image.Rect(0, 0, 20, 10), color.NRGBA{0, 255, 0, 255}, false},
+		{"translate without fit", map[string]string{"m": "1|0|5|0|1|0", "fit": "0", "bg": "black"}, image.Rect(0, 0, 20, 10), color.NRGBA{0, 0, 0, 255}, false},
+		{"missing matrix", map[string]string{}, image.Rectangle{}, color.NRGBA{}, true},
+		{"short matrix", map[string]string{"m": "1|0|0|0|1"}, image.Rectangle{}, color.NRGBA{}, true},
+		{"singular matrix", map[string]string{"m": "1|1|0|1|1|0"}, image.Rectangle{}, color.NRGBA{}, true},
+		{"unknown interpolation", map[string]string{"m": "1|0|0|0|1|0", "interp": "lanczos"}, image.Rectangle{}, color.NRGBA{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := manipulator.Execute(c, tt.params, testImg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if result.Bounds() != tt.wantBounds {
+				t.Errorf("bounds = %v, want %v", result.Bounds(), tt.wantBounds)
+			}
+
+			if got := color.NRGBAModel.Convert(result.At(0, 0)).(color.NRGBA); got != tt.wantCorner {
+				t.Errorf("corner color = %v, want %v", got, tt.wantCorner)
+			}
+		})
+	}
+}
+
+func TestPerspectiveManipulator(t *testing.T) {
+	manipulator := NewPerspectiveManipulator(&config.Config{})
+	testImg := newQuadrantsImage(20, 20)
+
+	var c *fiber.Ctx
+
+	// Straighten the bottom-right quadrant into an upright 10x10 image
+	result, err := manipulator.Execute(c, map[string]string{"from": "10|10|20|10|20|20|10|20"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.Bounds() != image.Rect(0, 0, 10, 10) {
+		t.Errorf("bounds = %v, want 10x10", result.Bounds())
+	}
+	if got := color.NRGBAModel.Convert(result.At(5, 5)).(color.NRGBA); got != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("expected the straightened quadrant to be white, got %v", got)
+	}
+
+	// Project the image onto a trapezoid, leaving the top corners transparent
+	result, err = manipulator.Execute(c, map[string]string{"to": "5|0|15|0|20|20|0|20"}, testImg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if a := alphaAt(result, 0, 0); a != 0 {
+		t.Errorf("expected the area outside of the trapezoid to be transparent, got alpha %d", a)
+	}
+	if got := color.NRGBAModel.Convert(result.At(10, 15)).(color.NRGBA); got.A != 255 {
+		t.Errorf("expected the bottom of the trapezoid to be opaque, got %v", got)
+	}
+
+	errorCases := []map[string]string{
+		{},
+		{"from": "0|0|1|1|2|2|3|3"},
+		{"to": "0|0|10|0"},
+	}
+	for _, params := range errorCases {
+		if _, err := manipulator.Execute(c, params, testImg); err == nil {
+			t.Errorf("expected an error for %v", params)
+		}
+	}
+}
@@ -96,6 +96,15 @@ func main() {
 	}
 
 	app.Get("/health", handlers.HandleHealth)
+	app.Get("/collage/*", handlers.HandleCollage)
+	app.Get("/manipulators", handlers.HandleManipulators)
+	app.Post("/pipeline", handlers.HandlePipeline)
+	app.Post("/batch", handlers.HandleBatch)
+
+	if cfg.Server.UploadEnabled {
+		app.Post("/upload/*", handlers.HandleUpload)
+		app.Put("/upload/*", handlers.HandleUpload)
+	}
 
 	for _, p := range cfg.Paths {
 		var path = p.Path